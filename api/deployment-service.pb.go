@@ -87,6 +87,8 @@ type Request struct {
 	Path                 string     `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
 	Mode                 ServerMode `protobuf:"varint,2,opt,name=mode,proto3,enum=api.ServerMode" json:"mode,omitempty"`
 	Recreate             bool       `protobuf:"varint,3,opt,name=recreate,proto3" json:"recreate,omitempty"`
+	Prune                bool       `protobuf:"varint,4,opt,name=prune,proto3" json:"prune,omitempty"`
+	Cluster              string     `protobuf:"bytes,5,opt,name=cluster,proto3" json:"cluster,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
 	XXX_unrecognized     []byte     `json:"-"`
 	XXX_sizecache        int32      `json:"-"`
@@ -138,9 +140,32 @@ func (m *Request) GetRecreate() bool {
 	return false
 }
 
+func (m *Request) GetPrune() bool {
+	if m != nil {
+		return m.Prune
+	}
+	return false
+}
+
+func (m *Request) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
 type ReleaseInfo struct {
 	ImageTag             string   `protobuf:"bytes,1,opt,name=image_tag,json=imageTag,proto3" json:"image_tag,omitempty"`
 	ReleaseDate          string   `protobuf:"bytes,2,opt,name=release_date,json=releaseDate,proto3" json:"release_date,omitempty"`
+	Pinned               bool     `protobuf:"varint,3,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	LatestTag            string   `protobuf:"bytes,4,opt,name=latest_tag,json=latestTag,proto3" json:"latest_tag,omitempty"`
+	NewerAvailable       bool     `protobuf:"varint,5,opt,name=newer_available,json=newerAvailable,proto3" json:"newer_available,omitempty"`
+	ImageDigest          string   `protobuf:"bytes,6,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	Name                 string   `protobuf:"bytes,7,opt,name=name,proto3" json:"name,omitempty"`
+	Description          string   `protobuf:"bytes,8,opt,name=description,proto3" json:"description,omitempty"`
+	Author               string   `protobuf:"bytes,9,opt,name=author,proto3" json:"author,omitempty"`
+	CommitSha            string   `protobuf:"bytes,10,opt,name=commit_sha,json=commitSha,proto3" json:"commit_sha,omitempty"`
+	WebUrl               string   `protobuf:"bytes,11,opt,name=web_url,json=webUrl,proto3" json:"web_url,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -185,6 +210,69 @@ func (m *ReleaseInfo) GetReleaseDate() string {
 	return ""
 }
 
+func (m *ReleaseInfo) GetPinned() bool {
+	if m != nil {
+		return m.Pinned
+	}
+	return false
+}
+
+func (m *ReleaseInfo) GetLatestTag() string {
+	if m != nil {
+		return m.LatestTag
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetNewerAvailable() bool {
+	if m != nil {
+		return m.NewerAvailable
+	}
+	return false
+}
+
+func (m *ReleaseInfo) GetImageDigest() string {
+	if m != nil {
+		return m.ImageDigest
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetAuthor() string {
+	if m != nil {
+		return m.Author
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetCommitSha() string {
+	if m != nil {
+		return m.CommitSha
+	}
+	return ""
+}
+
+func (m *ReleaseInfo) GetWebUrl() string {
+	if m != nil {
+		return m.WebUrl
+	}
+	return ""
+}
+
 type ServiceID struct {
 	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
 	Package              string   `protobuf:"bytes,2,opt,name=package,proto3" json:"package,omitempty"`
@@ -249,6 +337,10 @@ type ServiceInfo struct {
 	//	*ServiceInfo_Action
 	//	*ServiceInfo_ErrorDescription
 	ActionVariants       isServiceInfo_ActionVariants `protobuf_oneof:"action_variants"`
+	Conflicts            []string                     `protobuf:"bytes,7,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	ServiceResource      *ResourceInfo                `protobuf:"bytes,8,opt,name=service_resource,json=serviceResource,proto3" json:"service_resource,omitempty"`
+	Cluster              string                       `protobuf:"bytes,9,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Namespace            *ResourceInfo                `protobuf:"bytes,10,opt,name=namespace,proto3" json:"namespace,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                     `json:"-"`
 	XXX_unrecognized     []byte                       `json:"-"`
 	XXX_sizecache        int32                        `json:"-"`
@@ -344,6 +436,34 @@ func (m *ServiceInfo) GetErrorDescription() string {
 	return ""
 }
 
+func (m *ServiceInfo) GetConflicts() []string {
+	if m != nil {
+		return m.Conflicts
+	}
+	return nil
+}
+
+func (m *ServiceInfo) GetServiceResource() *ResourceInfo {
+	if m != nil {
+		return m.ServiceResource
+	}
+	return nil
+}
+
+func (m *ServiceInfo) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *ServiceInfo) GetNamespace() *ResourceInfo {
+	if m != nil {
+		return m.Namespace
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*ServiceInfo) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
@@ -471,6 +591,538 @@ func (*Response) XXX_OneofWrappers() []interface{} {
 	}
 }
 
+type ServiceDiff struct {
+	Path          string       `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Provider      string       `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	ServiceId     *ServiceID   `protobuf:"bytes,3,opt,name=serviceId,proto3" json:"serviceId,omitempty"`
+	Release       *ReleaseInfo `protobuf:"bytes,4,opt,name=release,proto3" json:"release,omitempty"`
+	LiveImageTag  string       `protobuf:"bytes,5,opt,name=live_image_tag,json=liveImageTag,proto3" json:"live_image_tag,omitempty"`
+	ImageUpToDate bool         `protobuf:"varint,6,opt,name=image_up_to_date,json=imageUpToDate,proto3" json:"image_up_to_date,omitempty"`
+	Drifted       bool         `protobuf:"varint,7,opt,name=drifted,proto3" json:"drifted,omitempty"`
+	// Types that are valid to be assigned to DiffVariants:
+	//	*ServiceDiff_Diff
+	//	*ServiceDiff_ErrorDescription
+	DiffVariants         isServiceDiff_DiffVariants `protobuf_oneof:"diff_variants"`
+	Cluster              string                     `protobuf:"bytes,10,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                   `json:"-"`
+	XXX_unrecognized     []byte                     `json:"-"`
+	XXX_sizecache        int32                      `json:"-"`
+}
+
+func (m *ServiceDiff) Reset()         { *m = ServiceDiff{} }
+func (m *ServiceDiff) String() string { return proto.CompactTextString(m) }
+func (*ServiceDiff) ProtoMessage()    {}
+func (*ServiceDiff) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{6}
+}
+
+func (m *ServiceDiff) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ServiceDiff.Unmarshal(m, b)
+}
+func (m *ServiceDiff) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ServiceDiff.Marshal(b, m, deterministic)
+}
+func (m *ServiceDiff) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ServiceDiff.Merge(m, src)
+}
+func (m *ServiceDiff) XXX_Size() int {
+	return xxx_messageInfo_ServiceDiff.Size(m)
+}
+func (m *ServiceDiff) XXX_DiscardUnknown() {
+	xxx_messageInfo_ServiceDiff.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ServiceDiff proto.InternalMessageInfo
+
+func (m *ServiceDiff) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ServiceDiff) GetProvider() string {
+	if m != nil {
+		return m.Provider
+	}
+	return ""
+}
+
+func (m *ServiceDiff) GetServiceId() *ServiceID {
+	if m != nil {
+		return m.ServiceId
+	}
+	return nil
+}
+
+func (m *ServiceDiff) GetRelease() *ReleaseInfo {
+	if m != nil {
+		return m.Release
+	}
+	return nil
+}
+
+func (m *ServiceDiff) GetLiveImageTag() string {
+	if m != nil {
+		return m.LiveImageTag
+	}
+	return ""
+}
+
+func (m *ServiceDiff) GetImageUpToDate() bool {
+	if m != nil {
+		return m.ImageUpToDate
+	}
+	return false
+}
+
+func (m *ServiceDiff) GetDrifted() bool {
+	if m != nil {
+		return m.Drifted
+	}
+	return false
+}
+
+type isServiceDiff_DiffVariants interface {
+	isServiceDiff_DiffVariants()
+}
+
+type ServiceDiff_Diff struct {
+	Diff string `protobuf:"bytes,8,opt,name=diff,proto3,oneof"`
+}
+
+type ServiceDiff_ErrorDescription struct {
+	ErrorDescription string `protobuf:"bytes,9,opt,name=error_description,json=errorDescription,proto3,oneof"`
+}
+
+func (*ServiceDiff_Diff) isServiceDiff_DiffVariants() {}
+
+func (*ServiceDiff_ErrorDescription) isServiceDiff_DiffVariants() {}
+
+func (m *ServiceDiff) GetDiffVariants() isServiceDiff_DiffVariants {
+	if m != nil {
+		return m.DiffVariants
+	}
+	return nil
+}
+
+func (m *ServiceDiff) GetDiff() string {
+	if x, ok := m.GetDiffVariants().(*ServiceDiff_Diff); ok {
+		return x.Diff
+	}
+	return ""
+}
+
+func (m *ServiceDiff) GetErrorDescription() string {
+	if x, ok := m.GetDiffVariants().(*ServiceDiff_ErrorDescription); ok {
+		return x.ErrorDescription
+	}
+	return ""
+}
+
+func (m *ServiceDiff) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*ServiceDiff) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*ServiceDiff_Diff)(nil),
+		(*ServiceDiff_ErrorDescription)(nil),
+	}
+}
+
+type DiffsResponse struct {
+	Services             []*ServiceDiff `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *DiffsResponse) Reset()         { *m = DiffsResponse{} }
+func (m *DiffsResponse) String() string { return proto.CompactTextString(m) }
+func (*DiffsResponse) ProtoMessage()    {}
+func (*DiffsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{7}
+}
+
+func (m *DiffsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiffsResponse.Unmarshal(m, b)
+}
+func (m *DiffsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiffsResponse.Marshal(b, m, deterministic)
+}
+func (m *DiffsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiffsResponse.Merge(m, src)
+}
+func (m *DiffsResponse) XXX_Size() int {
+	return xxx_messageInfo_DiffsResponse.Size(m)
+}
+func (m *DiffsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiffsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiffsResponse proto.InternalMessageInfo
+
+func (m *DiffsResponse) GetServices() []*ServiceDiff {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+type DiffResponse struct {
+	// Types that are valid to be assigned to ResponseVariants:
+	//	*DiffResponse_DiffsResponse
+	//	*DiffResponse_ErrorDescription
+	ResponseVariants     isDiffResponse_ResponseVariants `protobuf_oneof:"response_variants"`
+	XXX_NoUnkeyedLiteral struct{}                        `json:"-"`
+	XXX_unrecognized     []byte                          `json:"-"`
+	XXX_sizecache        int32                           `json:"-"`
+}
+
+func (m *DiffResponse) Reset()         { *m = DiffResponse{} }
+func (m *DiffResponse) String() string { return proto.CompactTextString(m) }
+func (*DiffResponse) ProtoMessage()    {}
+func (*DiffResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{8}
+}
+
+func (m *DiffResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiffResponse.Unmarshal(m, b)
+}
+func (m *DiffResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiffResponse.Marshal(b, m, deterministic)
+}
+func (m *DiffResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiffResponse.Merge(m, src)
+}
+func (m *DiffResponse) XXX_Size() int {
+	return xxx_messageInfo_DiffResponse.Size(m)
+}
+func (m *DiffResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiffResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiffResponse proto.InternalMessageInfo
+
+type isDiffResponse_ResponseVariants interface {
+	isDiffResponse_ResponseVariants()
+}
+
+type DiffResponse_DiffsResponse struct {
+	DiffsResponse *DiffsResponse `protobuf:"bytes,1,opt,name=diffs_response,json=diffsResponse,proto3,oneof"`
+}
+
+type DiffResponse_ErrorDescription struct {
+	ErrorDescription string `protobuf:"bytes,2,opt,name=error_description,json=errorDescription,proto3,oneof"`
+}
+
+func (*DiffResponse_DiffsResponse) isDiffResponse_ResponseVariants() {}
+
+func (*DiffResponse_ErrorDescription) isDiffResponse_ResponseVariants() {}
+
+func (m *DiffResponse) GetResponseVariants() isDiffResponse_ResponseVariants {
+	if m != nil {
+		return m.ResponseVariants
+	}
+	return nil
+}
+
+func (m *DiffResponse) GetDiffsResponse() *DiffsResponse {
+	if x, ok := m.GetResponseVariants().(*DiffResponse_DiffsResponse); ok {
+		return x.DiffsResponse
+	}
+	return nil
+}
+
+func (m *DiffResponse) GetErrorDescription() string {
+	if x, ok := m.GetResponseVariants().(*DiffResponse_ErrorDescription); ok {
+		return x.ErrorDescription
+	}
+	return ""
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*DiffResponse) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*DiffResponse_DiffsResponse)(nil),
+		(*DiffResponse_ErrorDescription)(nil),
+	}
+}
+
+type ResourceInfo struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Action               Action   `protobuf:"varint,3,opt,name=action,proto3,enum=api.Action" json:"action,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResourceInfo) Reset()         { *m = ResourceInfo{} }
+func (m *ResourceInfo) String() string { return proto.CompactTextString(m) }
+func (*ResourceInfo) ProtoMessage()    {}
+func (*ResourceInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{9}
+}
+
+func (m *ResourceInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResourceInfo.Unmarshal(m, b)
+}
+func (m *ResourceInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResourceInfo.Marshal(b, m, deterministic)
+}
+func (m *ResourceInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResourceInfo.Merge(m, src)
+}
+func (m *ResourceInfo) XXX_Size() int {
+	return xxx_messageInfo_ResourceInfo.Size(m)
+}
+func (m *ResourceInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResourceInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResourceInfo proto.InternalMessageInfo
+
+func (m *ResourceInfo) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *ResourceInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ResourceInfo) GetAction() Action {
+	if m != nil {
+		return m.Action
+	}
+	return Action_Created
+}
+
+type HistoryEntry struct {
+	ImageTag             string   `protobuf:"bytes,1,opt,name=image_tag,json=imageTag,proto3" json:"image_tag,omitempty"`
+	Time                 string   `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Actor                string   `protobuf:"bytes,3,opt,name=actor,proto3" json:"actor,omitempty"`
+	Action               Action   `protobuf:"varint,4,opt,name=action,proto3,enum=api.Action" json:"action,omitempty"`
+	ManifestHash         string   `protobuf:"bytes,5,opt,name=manifest_hash,json=manifestHash,proto3" json:"manifest_hash,omitempty"`
+	Path                 string   `protobuf:"bytes,6,opt,name=path,proto3" json:"path,omitempty"`
+	Cluster              string   `protobuf:"bytes,7,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Namespace            string   `protobuf:"bytes,8,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HistoryEntry) Reset()         { *m = HistoryEntry{} }
+func (m *HistoryEntry) String() string { return proto.CompactTextString(m) }
+func (*HistoryEntry) ProtoMessage()    {}
+func (*HistoryEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{10}
+}
+
+func (m *HistoryEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HistoryEntry.Unmarshal(m, b)
+}
+func (m *HistoryEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HistoryEntry.Marshal(b, m, deterministic)
+}
+func (m *HistoryEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HistoryEntry.Merge(m, src)
+}
+func (m *HistoryEntry) XXX_Size() int {
+	return xxx_messageInfo_HistoryEntry.Size(m)
+}
+func (m *HistoryEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_HistoryEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HistoryEntry proto.InternalMessageInfo
+
+func (m *HistoryEntry) GetImageTag() string {
+	if m != nil {
+		return m.ImageTag
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetTime() string {
+	if m != nil {
+		return m.Time
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetActor() string {
+	if m != nil {
+		return m.Actor
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetAction() Action {
+	if m != nil {
+		return m.Action
+	}
+	return Action_Created
+}
+
+func (m *HistoryEntry) GetManifestHash() string {
+	if m != nil {
+		return m.ManifestHash
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *HistoryEntry) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+type HistoryEntries struct {
+	Entries              []*HistoryEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *HistoryEntries) Reset()         { *m = HistoryEntries{} }
+func (m *HistoryEntries) String() string { return proto.CompactTextString(m) }
+func (*HistoryEntries) ProtoMessage()    {}
+func (*HistoryEntries) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{11}
+}
+
+func (m *HistoryEntries) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HistoryEntries.Unmarshal(m, b)
+}
+func (m *HistoryEntries) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HistoryEntries.Marshal(b, m, deterministic)
+}
+func (m *HistoryEntries) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HistoryEntries.Merge(m, src)
+}
+func (m *HistoryEntries) XXX_Size() int {
+	return xxx_messageInfo_HistoryEntries.Size(m)
+}
+func (m *HistoryEntries) XXX_DiscardUnknown() {
+	xxx_messageInfo_HistoryEntries.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HistoryEntries proto.InternalMessageInfo
+
+func (m *HistoryEntries) GetEntries() []*HistoryEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type HistoryResponse struct {
+	// Types that are valid to be assigned to ResponseVariants:
+	//	*HistoryResponse_HistoryResponse
+	//	*HistoryResponse_ErrorDescription
+	ResponseVariants     isHistoryResponse_ResponseVariants `protobuf_oneof:"response_variants"`
+	XXX_NoUnkeyedLiteral struct{}                           `json:"-"`
+	XXX_unrecognized     []byte                             `json:"-"`
+	XXX_sizecache        int32                              `json:"-"`
+}
+
+func (m *HistoryResponse) Reset()         { *m = HistoryResponse{} }
+func (m *HistoryResponse) String() string { return proto.CompactTextString(m) }
+func (*HistoryResponse) ProtoMessage()    {}
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_210f234a7064ba9a, []int{12}
+}
+
+func (m *HistoryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HistoryResponse.Unmarshal(m, b)
+}
+func (m *HistoryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HistoryResponse.Marshal(b, m, deterministic)
+}
+func (m *HistoryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HistoryResponse.Merge(m, src)
+}
+func (m *HistoryResponse) XXX_Size() int {
+	return xxx_messageInfo_HistoryResponse.Size(m)
+}
+func (m *HistoryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_HistoryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HistoryResponse proto.InternalMessageInfo
+
+type isHistoryResponse_ResponseVariants interface {
+	isHistoryResponse_ResponseVariants()
+}
+
+type HistoryResponse_HistoryResponse struct {
+	HistoryResponse *HistoryEntries `protobuf:"bytes,1,opt,name=history_response,json=historyResponse,proto3,oneof"`
+}
+
+type HistoryResponse_ErrorDescription struct {
+	ErrorDescription string `protobuf:"bytes,2,opt,name=error_description,json=errorDescription,proto3,oneof"`
+}
+
+func (*HistoryResponse_HistoryResponse) isHistoryResponse_ResponseVariants() {}
+
+func (*HistoryResponse_ErrorDescription) isHistoryResponse_ResponseVariants() {}
+
+func (m *HistoryResponse) GetResponseVariants() isHistoryResponse_ResponseVariants {
+	if m != nil {
+		return m.ResponseVariants
+	}
+	return nil
+}
+
+func (m *HistoryResponse) GetHistoryResponse() *HistoryEntries {
+	if x, ok := m.GetResponseVariants().(*HistoryResponse_HistoryResponse); ok {
+		return x.HistoryResponse
+	}
+	return nil
+}
+
+func (m *HistoryResponse) GetErrorDescription() string {
+	if x, ok := m.GetResponseVariants().(*HistoryResponse_ErrorDescription); ok {
+		return x.ErrorDescription
+	}
+	return ""
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*HistoryResponse) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*HistoryResponse_HistoryResponse)(nil),
+		(*HistoryResponse_ErrorDescription)(nil),
+	}
+}
+
 func init() {
 	proto.RegisterEnum("api.ServerMode", ServerMode_name, ServerMode_value)
 	proto.RegisterEnum("api.Action", Action_name, Action_value)
@@ -480,6 +1132,13 @@ func init() {
 	proto.RegisterType((*ServiceInfo)(nil), "api.ServiceInfo")
 	proto.RegisterType((*ServicesResponse)(nil), "api.ServicesResponse")
 	proto.RegisterType((*Response)(nil), "api.Response")
+	proto.RegisterType((*ServiceDiff)(nil), "api.ServiceDiff")
+	proto.RegisterType((*DiffsResponse)(nil), "api.DiffsResponse")
+	proto.RegisterType((*DiffResponse)(nil), "api.DiffResponse")
+	proto.RegisterType((*ResourceInfo)(nil), "api.ResourceInfo")
+	proto.RegisterType((*HistoryEntry)(nil), "api.HistoryEntry")
+	proto.RegisterType((*HistoryEntries)(nil), "api.HistoryEntries")
+	proto.RegisterType((*HistoryResponse)(nil), "api.HistoryResponse")
 }
 
 func init() {
@@ -487,40 +1146,74 @@ func init() {
 }
 
 var fileDescriptor_210f234a7064ba9a = []byte{
-	// 519 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x53, 0x41, 0x6f, 0xd3, 0x30,
-	0x14, 0x6e, 0xda, 0xae, 0x4d, 0x5e, 0xb6, 0x36, 0x35, 0x20, 0x45, 0xe5, 0x52, 0x82, 0x10, 0x55,
-	0xb5, 0xf6, 0x50, 0xc4, 0x1d, 0x58, 0x0e, 0xeb, 0x61, 0x03, 0x79, 0x70, 0xa5, 0x32, 0xf1, 0x23,
-	0x8b, 0xd6, 0xc6, 0xc6, 0x49, 0x23, 0x71, 0xe3, 0x47, 0xf0, 0x83, 0x91, 0x1d, 0x27, 0xad, 0xd0,
-	0x76, 0xcb, 0xf7, 0xde, 0xe7, 0xcf, 0xdf, 0xfb, 0xe2, 0x07, 0x21, 0x47, 0xb9, 0x13, 0xbf, 0xf7,
-	0x98, 0x97, 0xcb, 0x02, 0x55, 0x95, 0x25, 0xb8, 0x92, 0x4a, 0x94, 0x82, 0xf4, 0x98, 0xcc, 0xa2,
-	0xef, 0x30, 0xa4, 0xf8, 0xeb, 0x80, 0x45, 0x49, 0x08, 0xf4, 0x25, 0x2b, 0xef, 0x43, 0x67, 0xe6,
-	0xcc, 0x3d, 0x6a, 0xbe, 0xc9, 0x6b, 0xe8, 0xef, 0x05, 0xc7, 0xb0, 0x3b, 0x73, 0xe6, 0xa3, 0xf5,
-	0x78, 0xc5, 0x64, 0xb6, 0xba, 0x43, 0x55, 0xa1, 0xba, 0x11, 0x1c, 0xa9, 0x69, 0x92, 0x29, 0xb8,
-	0x0a, 0x13, 0x85, 0xac, 0xc4, 0xb0, 0x37, 0x73, 0xe6, 0x2e, 0x6d, 0x71, 0x74, 0x03, 0x3e, 0xc5,
-	0x1d, 0xb2, 0x02, 0x37, 0xf9, 0x4f, 0x41, 0x5e, 0x82, 0x97, 0xed, 0x59, 0x8a, 0xdb, 0x92, 0xa5,
-	0xf6, 0x22, 0xd7, 0x14, 0xbe, 0xb2, 0x94, 0xbc, 0x82, 0x73, 0x55, 0x73, 0xb7, 0x5c, 0x6b, 0x75,
-	0x4d, 0xdf, 0xb7, 0xb5, 0x58, 0xcb, 0x7d, 0x06, 0xef, 0xae, 0x1e, 0x62, 0x13, 0x93, 0xe7, 0x70,
-	0x96, 0x2a, 0x71, 0x90, 0x56, 0xa8, 0x06, 0x24, 0x84, 0xa1, 0x64, 0xc9, 0x03, 0x4b, 0x1b, 0x81,
-	0x06, 0xea, 0x01, 0x1f, 0xb2, 0x9c, 0x1b, 0x8f, 0x1e, 0x35, 0xdf, 0xd1, 0x9f, 0x2e, 0xf8, 0x8d,
-	0xa2, 0x36, 0xf8, 0x58, 0x08, 0x53, 0x70, 0xa5, 0x12, 0x55, 0xc6, 0x51, 0x59, 0xc9, 0x16, 0x93,
-	0x4b, 0xf0, 0x6c, 0xaa, 0x9b, 0x5a, 0xd8, 0x5f, 0x8f, 0xda, 0x94, 0x8c, 0x4d, 0x7a, 0x24, 0x90,
-	0x05, 0x0c, 0xed, 0x34, 0x61, 0xdf, 0x70, 0x03, 0xc3, 0x3d, 0x49, 0x88, 0x36, 0x04, 0xf2, 0x06,
-	0x06, 0x2c, 0x29, 0x33, 0x91, 0x87, 0x67, 0x26, 0x7c, 0xdf, 0x50, 0x3f, 0x9a, 0xd2, 0x75, 0x87,
-	0xda, 0x26, 0x59, 0xc2, 0x04, 0x95, 0x12, 0x6a, 0xcb, 0xb1, 0x48, 0x54, 0x26, 0xcd, 0x89, 0x81,
-	0x76, 0x79, 0xdd, 0xa1, 0x81, 0x69, 0xc5, 0xc7, 0xce, 0xa7, 0x09, 0x8c, 0xeb, 0x83, 0xdb, 0x8a,
-	0xa9, 0x8c, 0xe5, 0x65, 0x11, 0x7d, 0x80, 0xc0, 0x9a, 0x2d, 0x28, 0x16, 0x52, 0xe4, 0x05, 0x92,
-	0x4b, 0x70, 0xad, 0xeb, 0x22, 0x74, 0x66, 0xbd, 0xd6, 0xe9, 0x49, 0x54, 0xb4, 0x65, 0x44, 0x7f,
-	0x1d, 0x70, 0xdb, 0xa3, 0x31, 0x4c, 0x9a, 0xc6, 0x56, 0xd9, 0xa2, 0x89, 0xd3, 0x5f, 0xbf, 0x38,
-	0xd5, 0x68, 0x2f, 0xd3, 0x3e, 0x8b, 0xff, 0x0d, 0x3c, 0x3a, 0x56, 0xf7, 0xc9, 0xb1, 0x9e, 0xc1,
-	0xa4, 0xb9, 0xab, 0x1d, 0x6c, 0xb1, 0x04, 0x38, 0xbe, 0x55, 0x32, 0x06, 0x3f, 0xc6, 0x0a, 0x77,
-	0x42, 0xea, 0x5d, 0x08, 0x3a, 0x64, 0x04, 0xf0, 0x45, 0x09, 0x7e, 0x30, 0x71, 0x04, 0xce, 0xe2,
-	0x16, 0x06, 0x75, 0xba, 0xc4, 0x87, 0xe1, 0x95, 0x79, 0xbe, 0x3c, 0xe8, 0x68, 0x40, 0x71, 0x2f,
-	0x2a, 0xe4, 0x81, 0xa3, 0xc1, 0x37, 0xc9, 0x4d, 0xa7, 0x4b, 0x2e, 0xc0, 0xa3, 0xf6, 0x9d, 0xf3,
-	0xa0, 0xa7, 0xf5, 0x6e, 0x45, 0x79, 0x75, 0xcf, 0xf2, 0x14, 0x79, 0xd0, 0x5f, 0xbf, 0x07, 0x88,
-	0xdb, 0xdd, 0x23, 0x6f, 0x61, 0x50, 0x23, 0x72, 0x6e, 0xff, 0xb9, 0xd9, 0xba, 0xe9, 0x85, 0x45,
-	0xb5, 0xf9, 0xa8, 0xf3, 0x63, 0x60, 0xb6, 0xf3, 0xdd, 0xbf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x81,
-	0x21, 0xfb, 0x9f, 0xb9, 0x03, 0x00, 0x00,
+	// 1072 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x56, 0xcd, 0x6e, 0x23, 0x45,
+	0x10, 0xf6, 0xd8, 0x5e, 0xdb, 0x53, 0xe3, 0x9f, 0x71, 0x6f, 0x80, 0x51, 0x58, 0x24, 0xe3, 0x80,
+	0x12, 0xc2, 0x26, 0x08, 0x73, 0x84, 0x95, 0x76, 0xb3, 0x46, 0x72, 0x0e, 0x2c, 0x68, 0xb2, 0x39,
+	0x71, 0xb0, 0x3a, 0x33, 0x65, 0xbb, 0xb5, 0xf6, 0xf4, 0xd0, 0xd3, 0x76, 0x94, 0x97, 0x80, 0x03,
+	0x48, 0x3c, 0x02, 0x6f, 0xc4, 0x4b, 0x70, 0xe1, 0x11, 0x50, 0xff, 0xcc, 0x4f, 0xb2, 0x09, 0xe2,
+	0xb0, 0x07, 0x6e, 0x5d, 0x5f, 0x57, 0x57, 0xd5, 0x54, 0x7d, 0xfd, 0x4d, 0x43, 0x10, 0x63, 0xba,
+	0xe6, 0x37, 0x1b, 0x4c, 0xe4, 0x49, 0x86, 0x62, 0xc7, 0x22, 0x3c, 0x4d, 0x05, 0x97, 0x9c, 0x34,
+	0x68, 0xca, 0xc6, 0x3f, 0x3b, 0xd0, 0x0e, 0xf1, 0xa7, 0x2d, 0x66, 0x92, 0x10, 0x68, 0xa6, 0x54,
+	0xae, 0x02, 0x67, 0xe4, 0x1c, 0xb9, 0xa1, 0x5e, 0x93, 0x03, 0x68, 0x6e, 0x78, 0x8c, 0x41, 0x7d,
+	0xe4, 0x1c, 0xf5, 0x27, 0x83, 0x53, 0x9a, 0xb2, 0xd3, 0x0b, 0x14, 0x3b, 0x14, 0xdf, 0xf1, 0x18,
+	0x43, 0xbd, 0x49, 0xf6, 0xa1, 0x23, 0x30, 0x12, 0x48, 0x25, 0x06, 0x8d, 0x91, 0x73, 0xd4, 0x09,
+	0x0b, 0x9b, 0xec, 0xc1, 0xa3, 0x54, 0x6c, 0x13, 0x0c, 0x9a, 0x7a, 0xc3, 0x18, 0x24, 0x80, 0x76,
+	0xb4, 0xde, 0x66, 0x12, 0x45, 0xf0, 0x48, 0x67, 0xcb, 0xcd, 0xf1, 0x9f, 0x75, 0xf0, 0x42, 0x5c,
+	0x23, 0xcd, 0xf0, 0x3c, 0x59, 0x70, 0xf2, 0x21, 0xb8, 0x6c, 0x43, 0x97, 0x38, 0x97, 0x74, 0x69,
+	0x2b, 0xeb, 0x68, 0xe0, 0x35, 0x5d, 0x92, 0x8f, 0xa1, 0x2b, 0x8c, 0xef, 0x3c, 0xa6, 0xd2, 0x54,
+	0xe9, 0x86, 0x9e, 0xc5, 0xa6, 0x2a, 0xff, 0xfb, 0xd0, 0x4a, 0x59, 0x92, 0x60, 0x6c, 0x2b, 0xb3,
+	0x16, 0xf9, 0x08, 0x60, 0x4d, 0x25, 0x66, 0x52, 0x07, 0x6e, 0xea, 0x83, 0xae, 0x41, 0x54, 0xe4,
+	0x43, 0x18, 0x24, 0x78, 0x8d, 0x62, 0x4e, 0x77, 0x94, 0xad, 0xe9, 0xd5, 0x1a, 0x75, 0xa1, 0x9d,
+	0xb0, 0xaf, 0xe1, 0x17, 0x39, 0xaa, 0x4a, 0x30, 0xf5, 0xc5, 0x6c, 0x89, 0x99, 0x0c, 0x5a, 0xa6,
+	0x04, 0x8d, 0x4d, 0xd9, 0xd2, 0xf6, 0x35, 0xa1, 0x1b, 0x0c, 0xda, 0xa6, 0xaf, 0x6a, 0x4d, 0x46,
+	0xe0, 0xc5, 0x98, 0x45, 0x82, 0xa5, 0x92, 0xf1, 0x24, 0xe8, 0x98, 0x53, 0x15, 0x48, 0x15, 0x4e,
+	0xb7, 0x72, 0xc5, 0x45, 0xe0, 0xea, 0x4d, 0x6b, 0xa9, 0xc2, 0x23, 0xbe, 0xd9, 0x30, 0x39, 0xcf,
+	0x56, 0x34, 0x00, 0x53, 0xb8, 0x41, 0x2e, 0x56, 0x94, 0x7c, 0x00, 0xed, 0x6b, 0xbc, 0x9a, 0x6f,
+	0xc5, 0x3a, 0xf0, 0xcc, 0xb9, 0x6b, 0xbc, 0xba, 0x14, 0xeb, 0xf1, 0xf7, 0xe0, 0x5e, 0x98, 0xf9,
+	0x9f, 0x4f, 0xd5, 0x54, 0x96, 0x82, 0x6f, 0x53, 0xdb, 0x51, 0x63, 0xa8, 0xa9, 0xa4, 0x34, 0x7a,
+	0x43, 0x97, 0x79, 0x27, 0x73, 0x53, 0x7d, 0xc2, 0x1b, 0x96, 0x98, 0x1e, 0xba, 0xa1, 0x5e, 0x8f,
+	0xff, 0x68, 0x80, 0x97, 0x47, 0x54, 0x93, 0xba, 0x8f, 0x3e, 0xfb, 0xd0, 0x49, 0x05, 0xdf, 0xb1,
+	0x18, 0x85, 0x0d, 0x59, 0xd8, 0xe4, 0x29, 0xb8, 0x96, 0x90, 0xe7, 0x26, 0xb0, 0x37, 0xe9, 0x17,
+	0xfc, 0xd2, 0x65, 0x86, 0xa5, 0x03, 0x39, 0x86, 0xb6, 0x1d, 0xab, 0x1e, 0x96, 0x37, 0xf1, 0xb5,
+	0x6f, 0x85, 0x2a, 0x61, 0xee, 0x40, 0x3e, 0x85, 0x16, 0x8d, 0x74, 0x5f, 0x1f, 0x69, 0xda, 0x7a,
+	0xda, 0xf5, 0x85, 0x86, 0x66, 0xb5, 0xd0, 0x6e, 0x92, 0x13, 0x18, 0xa2, 0x10, 0x5c, 0xcc, 0xab,
+	0x93, 0xd0, 0xf3, 0x9b, 0xd5, 0x42, 0x5f, 0x6f, 0x4d, 0xcb, 0x1d, 0xf2, 0x04, 0xdc, 0x88, 0x27,
+	0x8b, 0x35, 0x8b, 0x64, 0x16, 0xb4, 0x47, 0x0d, 0xd3, 0x77, 0x0b, 0x90, 0x6f, 0xc0, 0xb7, 0xc5,
+	0xce, 0x05, 0x66, 0x7c, 0x2b, 0x22, 0xd4, 0x53, 0xf5, 0x26, 0x43, 0x5b, 0xa8, 0x01, 0x75, 0xa5,
+	0x03, 0xeb, 0x9a, 0x83, 0xd5, 0xfb, 0xe0, 0xde, 0xba, 0x0f, 0xe4, 0x0b, 0x70, 0x15, 0x61, 0xb2,
+	0x94, 0x46, 0x18, 0xc0, 0x43, 0x01, 0x4b, 0x9f, 0xb3, 0x21, 0x0c, 0xcc, 0xf7, 0xcd, 0x77, 0x54,
+	0x30, 0x9a, 0xc8, 0x6c, 0xfc, 0x1c, 0x7c, 0xdb, 0xd3, 0x2c, 0xc4, 0x2c, 0xe5, 0x49, 0x86, 0xe4,
+	0x29, 0x74, 0x6c, 0x11, 0x59, 0xe0, 0x8c, 0x1a, 0x45, 0x43, 0x2b, 0x13, 0x0d, 0x0b, 0x8f, 0xf1,
+	0x6f, 0x0e, 0x74, 0x8a, 0xa3, 0x53, 0x18, 0xe6, 0x1b, 0x73, 0x61, 0x41, 0x3d, 0x75, 0x6f, 0xf2,
+	0x5e, 0x35, 0x46, 0x91, 0x4c, 0xb5, 0x33, 0xbb, 0x5b, 0xc0, 0xbd, 0xdd, 0xaf, 0x3f, 0xd4, 0xfd,
+	0xb3, 0xc7, 0x30, 0xcc, 0x73, 0x95, 0x1f, 0xf6, 0x77, 0xbd, 0xa0, 0xe0, 0x94, 0x2d, 0x16, 0xff,
+	0x23, 0x0a, 0x7e, 0x02, 0xfd, 0x35, 0xdb, 0xe1, 0xbc, 0xd4, 0x2e, 0xa3, 0x73, 0x5d, 0x85, 0x9e,
+	0xe7, 0xfa, 0x75, 0x08, 0xbe, 0x71, 0xd8, 0xa6, 0x73, 0xc9, 0x8d, 0x86, 0xb5, 0xb4, 0xcc, 0xf4,
+	0x34, 0x7e, 0x99, 0xbe, 0xe6, 0x5a, 0xc5, 0x02, 0x68, 0xc7, 0x82, 0x2d, 0x24, 0xc6, 0x5a, 0x45,
+	0x3a, 0x61, 0x6e, 0x92, 0x3d, 0x68, 0xc6, 0x6c, 0xb1, 0x30, 0x0a, 0x32, 0xab, 0x85, 0xda, 0xba,
+	0xbf, 0xb9, 0xee, 0x83, 0xd4, 0xae, 0xd0, 0x0f, 0x6e, 0xd1, 0xef, 0x6c, 0x00, 0x3d, 0x15, 0xb0,
+	0x6c, 0xf9, 0x33, 0xe8, 0xa9, 0x56, 0xff, 0x67, 0x22, 0x29, 0xe7, 0x0a, 0x91, 0x7e, 0x71, 0xa0,
+	0xab, 0xa1, 0xfc, 0xf8, 0xd7, 0xd0, 0x57, 0x09, 0xde, 0x62, 0x12, 0xd1, 0x41, 0x6e, 0xa5, 0x9a,
+	0xd5, 0xc2, 0x5e, 0x5c, 0x05, 0xde, 0x09, 0x87, 0x7e, 0x84, 0x6e, 0xf5, 0x2a, 0x15, 0x52, 0xe7,
+	0x94, 0x52, 0x57, 0x28, 0x78, 0xbd, 0xa2, 0xe0, 0x07, 0x85, 0xc8, 0x34, 0xde, 0x12, 0x99, 0x5c,
+	0x62, 0xc6, 0x7f, 0x39, 0xd0, 0x9d, 0xb1, 0x4c, 0x72, 0x71, 0xf3, 0x6d, 0x22, 0xc5, 0xcd, 0xbf,
+	0xff, 0xce, 0x08, 0x34, 0x25, 0x2b, 0xd3, 0xa8, 0xb5, 0x52, 0x6a, 0x1a, 0x49, 0x2e, 0xac, 0xf4,
+	0x1a, 0xa3, 0x92, 0xbc, 0xf9, 0x60, 0x72, 0x72, 0x00, 0xbd, 0x0d, 0x4d, 0xd8, 0x42, 0xfd, 0xe4,
+	0x56, 0x34, 0x5b, 0xe5, 0x14, 0xcc, 0xc1, 0x19, 0xcd, 0x56, 0xc5, 0x95, 0x69, 0x55, 0xae, 0x4c,
+	0x85, 0x0e, 0xed, 0xdb, 0x6a, 0xf4, 0xa4, 0xaa, 0x46, 0xe6, 0xa7, 0x55, 0x02, 0xe3, 0x67, 0xd0,
+	0xaf, 0x7c, 0x2c, 0xc3, 0x8c, 0x7c, 0x0e, 0x6d, 0x34, 0x4b, 0xcb, 0x0d, 0xa3, 0x5d, 0xd5, 0x96,
+	0x84, 0xb9, 0xc7, 0xf8, 0x77, 0x07, 0x06, 0x76, 0xa7, 0x98, 0xf0, 0x73, 0xf0, 0x57, 0x06, 0xba,
+	0x4b, 0x90, 0xc7, 0x77, 0x23, 0x31, 0xcc, 0x66, 0xb5, 0x70, 0xb0, 0xba, 0x13, 0xe1, 0x1d, 0x70,
+	0xe4, 0xf8, 0x04, 0xa0, 0x7c, 0xf4, 0x90, 0x01, 0x78, 0x53, 0xdc, 0xe1, 0x9a, 0xa7, 0xea, 0x55,
+	0xe5, 0xd7, 0x48, 0x1f, 0xe0, 0x07, 0xc1, 0xe3, 0xad, 0x6e, 0xbb, 0xef, 0x1c, 0xbf, 0x82, 0x96,
+	0x19, 0x05, 0xf1, 0xa0, 0xfd, 0x52, 0xbf, 0x83, 0x62, 0xbf, 0xa6, 0x8c, 0x10, 0x37, 0x7c, 0x87,
+	0xb1, 0xef, 0x28, 0xe3, 0x32, 0x8d, 0xf5, 0x4e, 0x9d, 0xf4, 0xc0, 0x0d, 0xed, 0x83, 0x29, 0xf6,
+	0x1b, 0x2a, 0xde, 0x2b, 0x2e, 0x5f, 0xae, 0x68, 0xb2, 0xc4, 0xd8, 0x6f, 0x4e, 0x7e, 0x75, 0x00,
+	0xa6, 0xc5, 0x33, 0x8e, 0x1c, 0x42, 0xcb, 0x58, 0xa4, 0x6b, 0x05, 0x48, 0xbf, 0xdf, 0xf6, 0x7b,
+	0xd6, 0x32, 0xd5, 0x8f, 0x6b, 0xe4, 0x33, 0x68, 0x6a, 0x59, 0xbc, 0xed, 0x36, 0x2c, 0x6e, 0x56,
+	0xc5, 0xf5, 0x4b, 0x68, 0xdb, 0x56, 0x92, 0x3b, 0x0a, 0xb8, 0xbf, 0x57, 0x6d, 0x74, 0x79, 0xe4,
+	0xaa, 0xa5, 0x9f, 0x91, 0x5f, 0xfd, 0x33, 0x00, 0xdd, 0x28, 0xa6, 0x9a, 0x62, 0x0a, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -536,6 +1229,8 @@ const _ = grpc.SupportPackageIsVersion6
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type DeploymentClient interface {
 	Deploy(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	Diff(ctx context.Context, in *Request, opts ...grpc.CallOption) (*DiffResponse, error)
+	History(ctx context.Context, in *ServiceID, opts ...grpc.CallOption) (*HistoryResponse, error)
 }
 
 type deploymentClient struct {
@@ -555,9 +1250,29 @@ func (c *deploymentClient) Deploy(ctx context.Context, in *Request, opts ...grpc
 	return out, nil
 }
 
+func (c *deploymentClient) Diff(ctx context.Context, in *Request, opts ...grpc.CallOption) (*DiffResponse, error) {
+	out := new(DiffResponse)
+	err := c.cc.Invoke(ctx, "/api.Deployment/Diff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deploymentClient) History(ctx context.Context, in *ServiceID, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, "/api.Deployment/History", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DeploymentServer is the server API for Deployment service.
 type DeploymentServer interface {
 	Deploy(context.Context, *Request) (*Response, error)
+	Diff(context.Context, *Request) (*DiffResponse, error)
+	History(context.Context, *ServiceID) (*HistoryResponse, error)
 }
 
 // UnimplementedDeploymentServer can be embedded to have forward compatible implementations.
@@ -567,6 +1282,12 @@ type UnimplementedDeploymentServer struct {
 func (*UnimplementedDeploymentServer) Deploy(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Deploy not implemented")
 }
+func (*UnimplementedDeploymentServer) Diff(ctx context.Context, req *Request) (*DiffResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (*UnimplementedDeploymentServer) History(ctx context.Context, req *ServiceID) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
 
 func RegisterDeploymentServer(s *grpc.Server, srv DeploymentServer) {
 	s.RegisterService(&_Deployment_serviceDesc, srv)
@@ -590,6 +1311,42 @@ func _Deployment_Deploy_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Deployment_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeploymentServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Deployment/Diff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeploymentServer).Diff(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Deployment_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeploymentServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Deployment/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeploymentServer).History(ctx, req.(*ServiceID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Deployment_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "api.Deployment",
 	HandlerType: (*DeploymentServer)(nil),
@@ -598,6 +1355,14 @@ var _Deployment_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Deploy",
 			Handler:    _Deployment_Deploy_Handler,
 		},
+		{
+			MethodName: "Diff",
+			Handler:    _Deployment_Diff_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Deployment_History_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "deployment-service.proto",
@@ -0,0 +1,105 @@
+package gitclient
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"demius.md/deployment-operator/api"
+)
+
+// DefaultCacheTTL is time of life of cached release, when ttl is not declared in config of provider
+const DefaultCacheTTL = time.Minute
+
+// metrics of git providers, exposed via expvar
+var (
+	cacheHits   = expvar.NewMap("gitclient_cache_hits")
+	cacheMisses = expvar.NewMap("gitclient_cache_misses")
+)
+
+type cachedRelease struct {
+	info    *api.ReleaseInfo
+	expires time.Time
+}
+
+// CachedClient cache releases loaded by git client, errors are not cached
+type CachedClient struct {
+	provider string
+	client   GitClient
+	ttl      time.Duration
+
+	lock     sync.Mutex
+	releases map[string]cachedRelease
+}
+
+// NewCachedClient create cache of releases over git client, ttl 0 means DefaultCacheTTL, negative ttl disables cache
+func NewCachedClient(provider string, client GitClient, ttl time.Duration) GitClient {
+	if ttl < 0 {
+		return client
+	}
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedClient{provider: provider, client: client, ttl: ttl, releases: make(map[string]cachedRelease)}
+}
+
+// ProviderName return docker registry provider name
+func (c *CachedClient) ProviderName() string {
+	return c.client.ProviderName()
+}
+
+// LoadImageTag load tag of docker image for project from cache or from git provider
+func (c *CachedClient) LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error) {
+	// policy is part of key, because pinned releases are loaded with own policy
+	policyKey, _ := json.Marshal(policy)
+	key := c.provider + "/" + groupName + "/" + projectName + "/" + mode + "/" + string(policyKey)
+
+	now := time.Now()
+	c.lock.Lock()
+	cached, ok := c.releases[key]
+	c.lock.Unlock()
+
+	if ok && now.Before(cached.expires) {
+		cacheHits.Add(c.provider, 1)
+		log.Printf("LoadImageTag %s/%s for mode %s: cache hit\n", groupName, projectName, mode)
+		return copyReleaseInfo(cached.info), nil
+	}
+	cacheMisses.Add(c.provider, 1)
+
+	info, err := c.client.LoadImageTag(groupName, projectName, mode, policy)
+	if err != nil || info == nil {
+		return info, err
+	}
+
+	c.lock.Lock()
+	for k, r := range c.releases {
+		if now.After(r.expires) {
+			delete(c.releases, k)
+		}
+	}
+	c.releases[key] = cachedRelease{copyReleaseInfo(info), now.Add(c.ttl)}
+	c.lock.Unlock()
+	return info, nil
+}
+
+// Invalidate remove cached releases of project, e.g. when new release is reported by webhook
+func (c *CachedClient) Invalidate(groupName, projectName string) {
+	prefix := c.provider + "/" + groupName + "/" + projectName + "/"
+	c.lock.Lock()
+	for key := range c.releases {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.releases, key)
+		}
+	}
+	c.lock.Unlock()
+}
+
+// copyReleaseInfo copy release, because callers modify returned release
+func copyReleaseInfo(info *api.ReleaseInfo) *api.ReleaseInfo {
+	return proto.Clone(info).(*api.ReleaseInfo)
+}
@@ -1,11 +1,47 @@
 package gitclient
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
 	"demius.md/deployment-operator/api"
 )
 
-// GitClient is abstraction over Github and Gitlab
+var (
+	// ErrGroupNotFound is returned when group, organization or user is not found in provider
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrProjectNotFound is returned when project is not found in existed group
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrNoReleases is returned when project does not have any release
+	ErrNoReleases = errors.New("project has no releases")
+)
+
+// GitClient is abstraction over Github, Gitlab, Gitea and container registry
 type GitClient interface {
-	LoadImageTag(groupName, projectName, mode string) (*api.ReleaseInfo, error)
+	LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error)
 	ProviderName() string
 }
+
+// decodeResponse decode json body of successful response and close it
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// formatDate format date of release in RFC3339, empty string for unknown date
+func formatDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(time.RFC3339)
+}
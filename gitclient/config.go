@@ -0,0 +1,67 @@
+package gitclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Config contains settings of connection to provider
+type Config struct {
+	Provider  string            // name of provider, by default host of provider
+	URL       string            // base url of provider, by default `https://<provider>`
+	Token     TokenSource       // api access secret token, requests are not authorized when nil
+	Transport http.RoundTripper // transport with tls settings of provider, http.DefaultTransport when nil
+	AuthHosts []string          // hosts of auth services of registry, which receive credentials, besides host of registry
+}
+
+// TLSConfig contains tls settings of connection to provider, certificates of server are verified by default
+type TLSConfig struct {
+	CAFile   string `yaml:"ca-file"`   // PEM bundle of trusted CAs, added to system ones
+	CertFile string `yaml:"cert-file"` // client certificate
+	KeyFile  string `yaml:"key-file"`  // key of client certificate
+	Insecure bool   `yaml:"insecure"`  // disable verification of server certificate
+}
+
+// NewTransport create http transport with tls settings
+func NewTransport(conf TLSConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.Insecure}
+
+	if conf.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("can not read CA file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file `%s` does not contain PEM certificates", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can not load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// baseURL return url of provider without trailing slash
+func (c Config) baseURL() string {
+	if c.URL != "" {
+		return strings.TrimRight(c.URL, "/")
+	}
+	return "https://" + c.Provider
+}
@@ -0,0 +1,131 @@
+package gitclient
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"demius.md/deployment-operator/api"
+)
+
+// GiteaTimeout is timeout of one request to gitea api
+const GiteaTimeout = 30 * time.Second
+
+// GiteaClient load releases from Gitea or Forgejo via api v1
+type GiteaClient struct {
+	baseURL    string
+	httpclient *http.Client
+}
+
+type giteaRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	CreatedAt   time.Time `json:"created_at"`
+	PublishedAt time.Time `json:"published_at"`
+	Author      struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+type giteaTag struct {
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// ConnectGitea connects to Gitea or Forgejo, by default `https://<provider>` is used as base url
+func ConnectGitea(conf Config) *GiteaClient {
+	return &GiteaClient{
+		baseURL: conf.baseURL(),
+		httpclient: &http.Client{
+			Timeout:   GiteaTimeout,
+			Transport: withToken(NewRateLimitTransport(conf.Provider, conf.Transport), conf.Token, "Authorization", "token "),
+		},
+	}
+}
+
+// ProviderName return docker registry provider name
+func (c *GiteaClient) ProviderName() string {
+	return "gitea"
+}
+
+// LoadImageTag load tag of docker image for project
+func (c *GiteaClient) LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error) {
+	selector, err := newTagSelector(policy, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/api/v1/repos/" + url.PathEscape(groupName) + "/" + url.PathEscape(projectName) + "/releases"
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(ReleasesPageSize))
+
+		var releases []giteaRelease
+		if err := c.get(path+"?"+query.Encode(), &releases); err != nil {
+			return nil, fmt.Errorf("list releases of project `%s/%s` error: %v", groupName, projectName, err)
+		}
+
+		rels := make([]Release, len(releases))
+		for i, rel := range releases {
+			releaseDate := rel.PublishedAt
+			if releaseDate.IsZero() {
+				releaseDate = rel.CreatedAt
+			}
+			rels[i] = Release{
+				Tag:        rel.TagName,
+				Draft:      rel.Draft,
+				Prerelease: rel.Prerelease,
+				Info: &api.ReleaseInfo{
+					ImageTag:    rel.TagName,
+					ReleaseDate: formatDate(releaseDate),
+					Name:        rel.Name,
+					Description: rel.Body,
+					Author:      rel.Author.Login,
+					WebUrl:      rel.HTMLURL,
+				},
+			}
+		}
+
+		// gitea may limit page size by its own setting, so only empty page is the last one
+		if selector.Add(rels) || len(releases) == 0 {
+			break
+		}
+	}
+
+	selected := selector.Selected()
+	if selected == nil {
+		return nil, fmt.Errorf("no release of project `%s/%s` matches tag policy for mode %s", groupName, projectName, mode)
+	}
+
+	// release contains only branch of commit, so commit is resolved by tag
+	var tag giteaTag
+	tagPath := "/api/v1/repos/" + url.PathEscape(groupName) + "/" + url.PathEscape(projectName) + "/tags/" + url.PathEscape(selected.Tag)
+	if err := c.get(tagPath, &tag); err != nil {
+		log.Printf("LoadImageTag, can not resolve commit of tag %s: %v\n", selected.Tag, err)
+	}
+	selected.Info.CommitSha = tag.Commit.SHA
+	return selected.Info, nil
+}
+
+func (c *GiteaClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpclient.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, v)
+}
@@ -3,7 +3,8 @@ package gitclient
 import (
 	"context"
 	"fmt"
-	// "strings"
+	"log"
+	"net/http"
 
 	"github.com/google/go-github/v31/github"
 	"golang.org/x/oauth2"
@@ -17,18 +18,25 @@ type GithubClient struct {
 	client *github.Client
 }
 
-// ConnectGithub connects to gitlab
+// ConnectGithub connects to github, url of config is base url of GitHub Enterprise server, empty for github.com
 // my connect token is: remote-api-token
-func ConnectGithub(provider, secret string) *GithubClient {
+func ConnectGithub(conf Config) *GithubClient {
 	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: secret},
-	)
-	tc := oauth2.NewClient(ctx, ts)
+	var transport http.RoundTripper = NewRateLimitTransport(conf.Provider, conf.Transport)
+	if conf.Token != nil {
+		// token is requested for every request, so rotated token is used without restart
+		transport = &oauth2.Transport{Source: oauth2Source{conf.Token}, Base: transport}
+	}
+	tc := &http.Client{Transport: transport}
 
-	client := github.NewClient(tc)
-	// client.SetBaseURL("https://git.mydomain.com/api/v4")
+	if conf.URL == "" {
+		return &GithubClient{ctx, github.NewClient(tc)}
+	}
 
+	client, err := github.NewEnterpriseClient(conf.URL, conf.URL, tc)
+	if err != nil {
+		panic(err.Error())
+	}
 	return &GithubClient{ctx, client}
 }
 
@@ -38,41 +46,77 @@ func (c *GithubClient) ProviderName() string {
 }
 
 // LoadImageTag load tag of docker image for project
-func (c *GithubClient) LoadImageTag(groupName, projectName, mode string) (*api.ReleaseInfo, error) {
-	organization, _, err := c.client.Organizations.Get(c.ctx, groupName)
+func (c *GithubClient) LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error) {
+	selector, err := newTagSelector(policy, mode)
 	if err != nil {
-		return nil, fmt.Errorf("find organization `%s` error: %v", groupName, err)
+		return nil, err
 	}
 
-	login := organization.GetLogin()
-	if len(login) == 0 {
-		return nil, fmt.Errorf("organization `%s` does not have login", groupName)
+	opts := &github.ListOptions{
+		PerPage: ReleasesPageSize,
 	}
+	for {
+		releases, resp, err := c.client.Repositories.ListReleases(c.ctx, groupName, projectName, opts)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return nil, c.notFoundError(groupName, projectName)
+			}
+			return nil, fmt.Errorf("list releases of project `%s` error: %v", projectName, err)
+		}
 
-	repository, _, err := c.client.Repositories.Get(c.ctx, login, projectName)
-	if err != nil {
-		return nil, fmt.Errorf("find project `%s` error: %v", projectName, err)
+		page := make([]Release, len(releases))
+		for i, rel := range releases {
+			page[i] = Release{
+				Tag:        rel.GetTagName(),
+				Draft:      rel.GetDraft(),
+				Prerelease: rel.GetPrerelease(),
+				Info: &api.ReleaseInfo{
+					ImageTag:    rel.GetTagName(),
+					ReleaseDate: formatDate(rel.GetPublishedAt().Time),
+					Name:        rel.GetName(),
+					Description: rel.GetBody(),
+					Author:      rel.GetAuthor().GetLogin(),
+					WebUrl:      rel.GetHTMLURL(),
+				},
+			}
+		}
+
+		if selector.Add(page) || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
 	}
 
-	releases, _, err := c.client.Repositories.ListReleases(c.ctx, login, repository.GetName(), &github.ListOptions{
-		Page: 0,
-		PerPage: 3,
-	})
+	selected := selector.Selected()
+	if selected == nil {
+		return nil, fmt.Errorf("no release of project `%s` matches tag policy for mode %s", projectName, mode)
+	}
+
+	// release contains only branch of commit, so commit is resolved by tag
+	sha, _, err := c.client.Repositories.GetCommitSHA1(c.ctx, groupName, projectName, "refs/tags/"+selected.Tag, "")
 	if err != nil {
-		return nil, fmt.Errorf("list releases of project `%s` error: %v", projectName, err)
+		log.Printf("LoadImageTag, can not resolve commit of tag %s: %v\n", selected.Tag, err)
 	}
-	for _, rel := range releases {
-		/*
-		if !strings.HasSuffix(rel.GetTagName(), mode) {
-			fmt.Printf("LoadImageTag, tag %s has invalid suffix, must be: %s\n", rel.GetTagName(), mode)
-			continue
-		}
-		*/
-		return &api.ReleaseInfo{
-			ImageTag:    rel.GetTagName(),
-			ReleaseDate: rel.GetPublishedAt().String(),
-		}, nil
+	selected.Info.CommitSha = sha
+	return selected.Info, nil
+}
+
+// notFoundError find out whether owner or repository is not found, owner may be organization or user
+func (c *GithubClient) notFoundError(groupName, projectName string) error {
+	_, resp, err := c.client.Organizations.Get(c.ctx, groupName)
+	if err == nil {
+		return fmt.Errorf("%w: `%s` in organization `%s`", ErrProjectNotFound, projectName, groupName)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("find organization `%s` error: %v", groupName, err)
 	}
 
-	return nil, nil
+	_, resp, err = c.client.Users.Get(c.ctx, groupName)
+	if err == nil {
+		return fmt.Errorf("%w: `%s` in repositories of user `%s`", ErrProjectNotFound, projectName, groupName)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("find user `%s` error: %v", groupName, err)
+	}
+	return fmt.Errorf("%w: organization or user `%s`", ErrGroupNotFound, groupName)
 }
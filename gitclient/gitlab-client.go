@@ -1,11 +1,10 @@
 package gitclient
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
-	// "strings"
-	"time"
+	"net/url"
+	"sync"
 
 	gitlab "github.com/xanzy/go-gitlab"
 
@@ -20,26 +19,31 @@ import (
 // GitlabClient incapsulate gitlab client api
 type GitlabClient struct {
 	httpclient *http.Client
-	client *gitlab.Client
+	client     *gitlab.Client
+
+	projectsLock sync.Mutex
+	projects     map[string]gitlabProject // projects by full path
+}
+
+type gitlabProject struct {
+	id     int
+	webURL string
 }
 
 // ConnectGitlab connects to gitlab
-func ConnectGitlab(provider, secret string) *GitlabClient {
-	// fmt.Printf("GITLAB api secret token: %s\n", secret)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	httpclient := &http.Client{Transport: tr}
+func ConnectGitlab(conf Config) *GitlabClient {
+	// token is set by transport, so rotated token is used without restart
+	httpclient := &http.Client{Transport: withToken(NewRateLimitTransport(conf.Provider, conf.Transport), conf.Token, "PRIVATE-TOKEN", "")}
 
-	git, err := gitlab.NewClient(secret,
-		gitlab.WithBaseURL("https://"+provider+"/api/v4"),
+	git, err := gitlab.NewClient("",
+		gitlab.WithBaseURL(conf.baseURL()+"/api/v4"),
 		gitlab.WithHTTPClient(httpclient),
 	)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	return &GitlabClient{httpclient, git}
+	return &GitlabClient{httpclient: httpclient, client: git, projects: make(map[string]gitlabProject)}
 }
 
 // ProviderName return docker registry provider name
@@ -47,64 +51,106 @@ func (c *GitlabClient) ProviderName() string {
 	return "gitLAB"
 }
 
-// LoadImageTag load tag of docker image for project
-func (c *GitlabClient) LoadImageTag(groupName, projectName, mode string) (*api.ReleaseInfo, error) {
-	// fmt.Printf("LoadImageTag, find: %s:%s\n", groupName, projectName)
+// LoadImageTag load tag of docker image for project, group may contain subgroups: `group/subgroup`
+func (c *GitlabClient) LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error) {
+	selector, err := newTagSelector(policy, mode)
+	if err != nil {
+		return nil, err
+	}
 
-	groups, _, err := c.client.Groups.SearchGroup(groupName)
+	project, err := c.findProject(groupName, projectName)
 	if err != nil {
-		fmt.Printf("LoadImageTag, find group error: %v\n", err)
-		return nil, fmt.Errorf("find group `%s` error: %v", groupName, err)
+		return nil, err
 	}
 
-	for _, g := range groups {
-		// fmt.Printf("LoadImageTag, found group: %v\n", g.ID)
-		owned := true
-		opt := &gitlab.ListGroupProjectsOptions{
-			Owned:  &owned,
-			Search: &projectName,
-		}
+	count, err := c.selectRelease(project, selector)
+	if err != nil {
+		// project may be moved or removed, so it is resolved again on next call
+		c.projectsLock.Lock()
+		delete(c.projects, groupName+"/"+projectName)
+		c.projectsLock.Unlock()
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: project `%s/%s`", ErrNoReleases, groupName, projectName)
+	}
+	if selected := selector.Selected(); selected != nil {
+		return selected.Info, nil
+	}
+	return nil, fmt.Errorf("no release of project `%s` matches tag policy for mode %s", projectName, mode)
+}
 
-		projects, _, err := c.client.Groups.ListGroupProjects(g.ID, opt)
-		if err != nil {
-			return nil, fmt.Errorf("find project `%s` error: %v", projectName, err)
+// findProject find project by full path, found projects are cached
+func (c *GitlabClient) findProject(groupName, projectName string) (gitlabProject, error) {
+	path := groupName + "/" + projectName
+
+	c.projectsLock.Lock()
+	cached, ok := c.projects[path]
+	c.projectsLock.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	project, resp, err := c.client.Projects.GetProject(path, nil)
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			return gitlabProject{}, fmt.Errorf("find project `%s` error: %v", path, err)
+		}
+		// find out whether group or project is not found
+		_, resp, err := c.client.Groups.GetGroup(groupName)
+		if err == nil {
+			return gitlabProject{}, fmt.Errorf("%w: `%s` in group `%s`", ErrProjectNotFound, projectName, groupName)
 		}
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return gitlabProject{}, fmt.Errorf("%w: `%s`", ErrGroupNotFound, groupName)
+		}
+		return gitlabProject{}, fmt.Errorf("find group `%s` error: %v", groupName, err)
+	}
 
-		for _, p := range projects {
-			fmt.Printf("LoadImageTag, found project: %v\n", p.Path)
-			if p.Path != projectName {
-				continue
-			}
+	cached = gitlabProject{project.ID, project.WebURL}
+	c.projectsLock.Lock()
+	c.projects[path] = cached
+	c.projectsLock.Unlock()
+	return cached, nil
+}
 
-			opt := &gitlab.ListReleasesOptions{
-				Page:    0,
-				PerPage: 3,
-			}
+// selectRelease load releases of project page by page until selector finish selection, return number of loaded releases
+func (c *GitlabClient) selectRelease(project gitlabProject, selector *tagSelector) (int, error) {
+	opt := &gitlab.ListReleasesOptions{
+		Page:    1,
+		PerPage: ReleasesPageSize,
+	}
+	count := 0
+	for {
+		releases, resp, err := c.client.Releases.ListReleases(project.id, opt)
+		if err != nil {
+			return count, fmt.Errorf("list release error: %v", err)
+		}
+		count += len(releases)
 
-			releases, _, err := c.client.Releases.ListReleases(p.ID, opt)
-			if err != nil {
-				return nil, fmt.Errorf("list release error: %v", err)
+		page := make([]Release, len(releases))
+		for i, rel := range releases {
+			releaseDate := ""
+			if rel.CreatedAt != nil {
+				releaseDate = formatDate(*rel.CreatedAt)
 			}
-			for _, rel := range releases {
-				/*
-				if !strings.HasSuffix(rel.TagName, mode) {
-					fmt.Printf("LoadImageTag, tag %s has invalid suffix, must be: %s\n", rel.TagName, mode)
-					continue
-				}
-				*/
-				createdAt := rel.CreatedAt
-				createdAtStr := ""
-				if createdAt != nil {
-					createdAtStr = createdAt.Format(time.RFC822)
-				}
-				return &api.ReleaseInfo{
+			page[i] = Release{
+				Tag: rel.TagName,
+				Info: &api.ReleaseInfo{
 					ImageTag:    rel.TagName,
-					ReleaseDate: createdAtStr,
-				}, nil
+					ReleaseDate: releaseDate,
+					Name:        rel.Name,
+					Description: rel.Description,
+					Author:      rel.Author.Username,
+					CommitSha:   rel.Commit.ID,
+					WebUrl:      project.webURL + "/-/releases/" + url.PathEscape(rel.TagName),
+				},
 			}
+		}
 
+		if selector.Add(page) || resp.NextPage == 0 {
+			return count, nil
 		}
+		opt.Page = resp.NextPage
 	}
-
-	return nil, nil
 }
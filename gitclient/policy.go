@@ -0,0 +1,254 @@
+package gitclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"demius.md/deployment-operator/api"
+)
+
+// ReleasesPageSize is number of releases loaded from git provider per request
+const ReleasesPageSize = 50
+
+// TagPolicy contains rules for selection of release tag, may be declared per provider or per kustomization
+type TagPolicy struct {
+	Semver bool               `yaml:"semver"` // order releases by semantic version instead of release date
+	Modes  map[string]TagRule `yaml:"modes"`  // rules per server mode: devel or prod
+	Pinned *TagRule           `yaml:"-"`      // rule of pinned release, selected from the same releases as latest one
+}
+
+// TagRule contains rules for selection of release tag for one server mode
+type TagRule struct {
+	Tag         string `yaml:"tag"`         // only release with this tag is selected
+	Suffix      string `yaml:"suffix"`      // tag must have suffix, e.g. `-prod`
+	Regex       string `yaml:"regex"`       // tag must match regular expression
+	Constraint  string `yaml:"constraint"`  // semver constraint, e.g. `~1.4`, implies semver ordering
+	Prereleases *bool  `yaml:"prereleases"` // allow prereleases, by default allowed in devel mode only
+	Drafts      bool   `yaml:"drafts"`      // allow draft releases
+}
+
+// Release is release of project loaded from git provider
+type Release struct {
+	Tag        string
+	Draft      bool
+	Prerelease bool
+	Info       *api.ReleaseInfo
+}
+
+// Rule return rule of policy for server mode
+func (p *TagPolicy) Rule(mode string) TagRule {
+	var rule TagRule
+	if p != nil {
+		rule = p.Modes[mode]
+	}
+	return ruleWithDefaults(rule, mode)
+}
+
+func ruleWithDefaults(rule TagRule, mode string) TagRule {
+	if rule.Prereleases == nil {
+		allowed := mode != "prod"
+		rule.Prereleases = &allowed
+	}
+	return rule
+}
+
+// Validate check regular expressions and version constraints of rules
+func (p *TagPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for mode := range p.Modes {
+		if _, err := newTagSelector(p, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pin return copy of policy, which select release with fixed tag or in range of versions for server mode.
+// Latest release matched to policy is selected in the same pass and reported in info of pinned release
+func (p *TagPolicy) Pin(mode, tag, version string) *TagPolicy {
+	pinned := &TagPolicy{}
+	if p != nil {
+		pinned.Semver = p.Semver
+		pinned.Modes = p.Modes
+	}
+
+	rule := TagRule{Tag: tag}
+	if tag == "" {
+		rule = pinned.Rule(mode)
+		rule.Constraint = version
+	}
+	pinned.Pinned = &rule
+	return pinned
+}
+
+// IsNewer check that tag is newer than other tag, tags which are not semantic versions are compared for equality only
+func IsNewer(tag, than string) bool {
+	if tag == "" || tag == than {
+		return false
+	}
+	version, err := semver.NewVersion(tag)
+	if err != nil {
+		return true
+	}
+	thanVersion, err := semver.NewVersion(than)
+	if err != nil {
+		return true
+	}
+	return version.GreaterThan(thanVersion)
+}
+
+// tagSelector select release by policy, while releases are loaded page by page from newest to oldest
+type tagSelector struct {
+	rule       TagRule
+	semver     bool
+	regex      *regexp.Regexp
+	constraint *semver.Constraints
+	pinned     *tagSelector // selector of pinned release, when policy is pinned
+
+	selected        *Release
+	selectedVersion *semver.Version
+	done            bool
+}
+
+func newTagSelector(policy *TagPolicy, mode string) (*tagSelector, error) {
+	selector, err := newRuleSelector(policy, policy.Rule(mode), mode)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil && policy.Pinned != nil {
+		if selector.pinned, err = newRuleSelector(policy, ruleWithDefaults(*policy.Pinned, mode), mode); err != nil {
+			return nil, err
+		}
+	}
+	return selector, nil
+}
+
+func newRuleSelector(policy *TagPolicy, rule TagRule, mode string) (*tagSelector, error) {
+	selector := &tagSelector{
+		rule:   rule,
+		semver: (policy != nil && policy.Semver) || rule.Constraint != "",
+	}
+
+	if rule.Regex != "" {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex `%s` for mode %s: %v", rule.Regex, mode, err)
+		}
+		selector.regex = regex
+	}
+
+	if rule.Constraint != "" {
+		constraint, err := semver.NewConstraint(rule.Constraint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint `%s` for mode %s: %v", rule.Constraint, mode, err)
+		}
+		selector.constraint = constraint
+	}
+	return selector, nil
+}
+
+// orderBySemver select releases by semantic version, e.g. for tags of registry without dates
+func (s *tagSelector) orderBySemver() {
+	s.semver = true
+	if s.pinned != nil {
+		s.pinned.semver = true
+	}
+}
+
+// Add check page of releases, return true when selection is finished and next pages are not needed.
+// Pinned and latest releases are selected from the same pages
+func (s *tagSelector) Add(releases []Release) bool {
+	if s.pinned == nil {
+		return s.add(releases)
+	}
+	pinnedDone := s.pinned.done || s.pinned.add(releases)
+	latestDone := s.done || s.add(releases)
+	return pinnedDone && latestDone
+}
+
+func (s *tagSelector) add(releases []Release) bool {
+	for i := range releases {
+		rel := &releases[i]
+		if !s.matchTag(rel) {
+			continue
+		}
+
+		if !s.semver || s.rule.Tag != "" {
+			// releases are ordered from newest, so first matched is selected
+			s.selected = rel
+			s.done = true
+			return true
+		}
+
+		version, err := semver.NewVersion(s.versionOf(rel.Tag))
+		if err != nil {
+			// tags which are not semantic versions are skipped
+			continue
+		}
+		if version.Prerelease() != "" && !*s.rule.Prereleases {
+			continue
+		}
+		if s.constraint != nil && !s.constraint.Check(version) {
+			continue
+		}
+		if s.selectedVersion == nil || version.GreaterThan(s.selectedVersion) {
+			s.selected = rel
+			s.selectedVersion = version
+		}
+	}
+	return false
+}
+
+// Selected return selected release, nil when no release matched to policy.
+// Pinned release is returned for pinned policy, info of pinned release contains latest tag
+func (s *tagSelector) Selected() *Release {
+	if s.pinned == nil {
+		return s.selected
+	}
+	pinned := s.pinned.selected
+	if pinned == nil {
+		return nil
+	}
+	pinned.Info.Pinned = true
+	if s.selected != nil {
+		pinned.Info.LatestTag = s.selected.Tag
+		pinned.Info.NewerAvailable = IsNewer(s.selected.Tag, pinned.Tag)
+	}
+	return pinned
+}
+
+func (s *tagSelector) matchTag(rel *Release) bool {
+	if rel.Tag == "" {
+		return false
+	}
+	if s.rule.Tag != "" {
+		// fixed tag is selected even if it is prerelease or draft
+		return rel.Tag == s.rule.Tag
+	}
+	if rel.Draft && !s.rule.Drafts {
+		return false
+	}
+	if rel.Prerelease && !*s.rule.Prereleases {
+		return false
+	}
+	if s.rule.Suffix != "" && !strings.HasSuffix(rel.Tag, s.rule.Suffix) {
+		return false
+	}
+	if s.regex != nil && !s.regex.MatchString(rel.Tag) {
+		return false
+	}
+	return true
+}
+
+// versionOf remove required suffix from tag, so `v1.4.2-prod` is parsed as `v1.4.2` and not as prerelease
+func (s *tagSelector) versionOf(tag string) string {
+	if s.rule.Suffix == "" {
+		return tag
+	}
+	return strings.TrimRight(strings.TrimSuffix(tag, s.rule.Suffix), "-._")
+}
@@ -0,0 +1,259 @@
+package gitclient
+
+import (
+	"testing"
+
+	"demius.md/deployment-operator/api"
+)
+
+func releasesOf(tags ...string) []Release {
+	releases := make([]Release, len(tags))
+	for i, tag := range tags {
+		releases[i] = Release{Tag: tag, Info: &api.ReleaseInfo{ImageTag: tag}}
+	}
+	return releases
+}
+
+func TestPinnedSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *TagPolicy
+		tag      string
+		version  string
+		pages    [][]string
+		selected string
+		latest   string
+		newer    bool
+	}{
+		{
+			name:     "pinned tag with newer release",
+			tag:      "v1.1.0",
+			pages:    [][]string{{"v1.2.0", "v1.1.0"}, {"v1.0.0"}},
+			selected: "v1.1.0",
+			latest:   "v1.2.0",
+			newer:    true,
+		},
+		{
+			name:     "pinned version by semver",
+			policy:   &TagPolicy{Semver: true},
+			version:  "~1.1",
+			pages:    [][]string{{"v1.1.2", "v2.0.0"}, {"v1.1.5", "v1.2.0"}},
+			selected: "v1.1.5",
+			latest:   "v2.0.0",
+			newer:    true,
+		},
+		{
+			name:     "pinned tag is latest release",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"prod": {Suffix: "-prod"}}},
+			tag:      "v1.0.0-prod",
+			pages:    [][]string{{"v1.1.0-dev", "v1.0.0-prod"}},
+			selected: "v1.0.0-prod",
+			latest:   "v1.0.0-prod",
+		},
+		{
+			name:     "pinned tag is deployed without releases matched to policy",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"prod": {Suffix: "-prod"}}},
+			tag:      "v1.0.0",
+			pages:    [][]string{{"v1.1.0", "v1.0.0"}},
+			selected: "v1.0.0",
+		},
+	}
+	for _, test := range tests {
+		selector, err := newTagSelector(test.policy.Pin("prod", test.tag, test.version), "prod")
+		if err != nil {
+			t.Fatalf("%s: newTagSelector error: %v", test.name, err)
+		}
+		for _, page := range test.pages {
+			if selector.Add(releasesOf(page...)) {
+				break
+			}
+		}
+		selected := selector.Selected()
+		if selected == nil {
+			t.Errorf("%s: release is not selected", test.name)
+			continue
+		}
+		if selected.Tag != test.selected || !selected.Info.Pinned {
+			t.Errorf("%s: selected %s, pinned %v, expected pinned %s", test.name, selected.Tag, selected.Info.Pinned, test.selected)
+		}
+		if selected.Info.LatestTag != test.latest || selected.Info.NewerAvailable != test.newer {
+			t.Errorf("%s: latest %s, newer %v, expected %s, %v", test.name, selected.Info.LatestTag, selected.Info.NewerAvailable, test.latest, test.newer)
+		}
+	}
+}
+
+func TestTagSelector(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name     string
+		policy   *TagPolicy
+		mode     string
+		pages    [][]Release
+		selected string
+	}{
+		{
+			name:     "newest release by date",
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.0.0", "v2.0.0")},
+			selected: "v1.0.0",
+		},
+		{
+			name:     "prereleases are skipped in prod mode",
+			mode:     "prod",
+			pages:    [][]Release{{{Tag: "v2.0.0-rc1", Prerelease: true}, {Tag: "v1.0.0"}}},
+			selected: "v1.0.0",
+		},
+		{
+			name:     "prereleases are allowed in devel mode",
+			mode:     "devel",
+			pages:    [][]Release{{{Tag: "v2.0.0-rc1", Prerelease: true}, {Tag: "v1.0.0"}}},
+			selected: "v2.0.0-rc1",
+		},
+		{
+			name:     "drafts are skipped",
+			mode:     "devel",
+			pages:    [][]Release{{{Tag: "v2.0.0", Draft: true}, {Tag: "v1.0.0"}}},
+			selected: "v1.0.0",
+		},
+		{
+			name:     "drafts are allowed by rule",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"devel": {Drafts: true}}},
+			mode:     "devel",
+			pages:    [][]Release{{{Tag: "v2.0.0", Draft: true}, {Tag: "v1.0.0"}}},
+			selected: "v2.0.0",
+		},
+		{
+			name:     "tag with suffix",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"prod": {Suffix: "-prod"}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.1.0-dev"), releasesOf("v1.0.0-prod")},
+			selected: "v1.0.0-prod",
+		},
+		{
+			name:     "tag matched to regex",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"prod": {Regex: `^release-\d+$`}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("latest", "release-12", "release-11")},
+			selected: "release-12",
+		},
+		{
+			name:     "highest semantic version from all pages",
+			policy:   &TagPolicy{Semver: true},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.2.0", "latest"), releasesOf("v1.10.0", "v1.9.0")},
+			selected: "v1.10.0",
+		},
+		{
+			name:     "semantic version of tag with suffix is not prerelease",
+			policy:   &TagPolicy{Semver: true, Modes: map[string]TagRule{"prod": {Suffix: "-prod"}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.2.0-prod", "v1.3.0-rc1-prod", "v1.10.0-dev")},
+			selected: "v1.2.0-prod",
+		},
+		{
+			name:     "prerelease versions are allowed by rule",
+			policy:   &TagPolicy{Semver: true, Modes: map[string]TagRule{"prod": {Prereleases: &yes}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.2.0", "v1.3.0-rc1")},
+			selected: "v1.3.0-rc1",
+		},
+		{
+			name:     "version constraint implies semver",
+			policy:   &TagPolicy{Modes: map[string]TagRule{"prod": {Constraint: "~1.4"}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.5.0", "v1.4.2", "v1.4.10", "v1.3.0")},
+			selected: "v1.4.10",
+		},
+		{
+			name:     "fixed tag",
+			policy:   &TagPolicy{Semver: true, Modes: map[string]TagRule{"prod": {Tag: "v1.0.0-rc1"}}},
+			mode:     "prod",
+			pages:    [][]Release{releasesOf("v1.1.0"), {{Tag: "v1.0.0-rc1", Prerelease: true}}},
+			selected: "v1.0.0-rc1",
+		},
+		{
+			name:  "no release matched",
+			mode:  "prod",
+			pages: [][]Release{releasesOf(""), {{Tag: "v1.0.0-rc1", Prerelease: true}}},
+		},
+	}
+	for _, test := range tests {
+		selector, err := newTagSelector(test.policy, test.mode)
+		if err != nil {
+			t.Fatalf("%s: newTagSelector error: %v", test.name, err)
+		}
+		for _, page := range test.pages {
+			if selector.Add(page) {
+				break
+			}
+		}
+		selected := ""
+		if release := selector.Selected(); release != nil {
+			selected = release.Tag
+		}
+		if selected != test.selected {
+			t.Errorf("%s: selected `%s`, expected `%s`", test.name, selected, test.selected)
+		}
+	}
+}
+
+func TestTagPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy *TagPolicy
+		valid  bool
+	}{
+		{nil, true},
+		{&TagPolicy{Modes: map[string]TagRule{"prod": {Regex: `^v\d+`, Constraint: ">=1.0, <2"}}}, true},
+		{&TagPolicy{Modes: map[string]TagRule{"prod": {Regex: `(`}}}, false},
+		{&TagPolicy{Modes: map[string]TagRule{"devel": {Constraint: "not a version"}}}, false},
+	}
+	for i, test := range tests {
+		if err := test.policy.Validate(); (err == nil) != test.valid {
+			t.Errorf("policy %d: Validate error %v, expected valid %v", i, err, test.valid)
+		}
+	}
+}
+
+func TestPin(t *testing.T) {
+	policy := &TagPolicy{Semver: true, Modes: map[string]TagRule{"prod": {Suffix: "-prod"}}}
+
+	pinned := policy.Pin("prod", "v1.0.0", "")
+	if !pinned.Semver || pinned.Pinned == nil || pinned.Pinned.Tag != "v1.0.0" || pinned.Pinned.Suffix != "" {
+		t.Errorf("policy pinned by tag: %+v", pinned)
+	}
+
+	pinned = policy.Pin("prod", "", "~1.4")
+	if pinned.Pinned == nil || pinned.Pinned.Constraint != "~1.4" || pinned.Pinned.Suffix != "-prod" {
+		t.Errorf("policy pinned by version: %+v", pinned)
+	}
+	if policy.Pinned != nil || policy.Modes["prod"].Constraint != "" {
+		t.Errorf("original policy is changed: %+v", policy)
+	}
+
+	var empty *TagPolicy
+	if pinned := empty.Pin("devel", "", "^2"); pinned.Pinned == nil || pinned.Pinned.Constraint != "^2" {
+		t.Errorf("empty policy pinned by version: %+v", pinned)
+	}
+}
+
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		tag, than string
+		newer     bool
+	}{
+		{"v1.2.0", "v1.1.0", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.1.0", "v1.2.0", false},
+		{"v1.1.0", "v1.1.0", false},
+		{"v1.1.0", "v1.1.0-rc1", true},
+		{"", "v1.0.0", false},
+		{"latest", "v1.0.0", true},
+		{"v1.0.0", "stable", true},
+		{"stable", "stable", false},
+	}
+	for _, test := range tests {
+		if newer := IsNewer(test.tag, test.than); newer != test.newer {
+			t.Errorf("IsNewer(%s, %s) = %v, expected %v", test.tag, test.than, newer, test.newer)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package gitclient
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// RateLimitRetries is maximum number of retries of request rejected by rate limit of provider
+	RateLimitRetries = 3
+	// RateLimitMaxWait is maximum time of waiting for reset of rate limit before retry
+	RateLimitMaxWait = time.Minute
+	// RateLimitBackoff is initial delay of retry, when provider does not declare time of reset
+	RateLimitBackoff = time.Second
+	// RateLimitLowRemaining is number of remaining requests, when low quota is reported in log
+	RateLimitLowRemaining = 50
+)
+
+// metrics of rate limits of git providers, exposed via expvar
+var (
+	rateLimited        = expvar.NewMap("gitclient_rate_limited")
+	rateLimitRemaining = expvar.NewMap("gitclient_rate_limit_remaining")
+)
+
+// RateLimitTransport retry requests rejected by rate limit of provider, honouring `Retry-After` and rate limit reset headers
+type RateLimitTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+// NewRateLimitTransport create transport over base transport, http.DefaultTransport is used when base is nil
+func NewRateLimitTransport(provider string, base http.RoundTripper) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{provider, base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := RateLimitBackoff
+	for retry := 0; ; retry++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+		t.reportRemaining(resp)
+
+		if !isRateLimited(resp) {
+			return resp, nil
+		}
+		rateLimited.Add(t.provider, 1)
+
+		// request with body may be retried only when body can be recreated
+		if retry >= RateLimitRetries || (req.Body != nil && req.GetBody == nil) {
+			log.Printf("rate limit of provider %s exceeded, request %s is rejected\n", t.provider, req.URL.Path)
+			return resp, nil
+		}
+
+		wait, ok := retryAfter(resp)
+		if !ok {
+			wait = backoff
+			backoff *= 2
+		}
+		if wait > RateLimitMaxWait {
+			log.Printf("rate limit of provider %s exceeded, reset in %v\n", t.provider, wait)
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		log.Printf("rate limit of provider %s exceeded, retry %d in %v\n", t.provider, retry+1, wait)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// reportRemaining report remaining quota of requests, github and gitea use `X-RateLimit-*` headers, gitlab `RateLimit-*`
+func (t *RateLimitTransport) reportRemaining(resp *http.Response) {
+	value := resp.Header.Get("X-RateLimit-Remaining")
+	if value == "" {
+		value = resp.Header.Get("RateLimit-Remaining")
+	}
+	remaining, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+
+	v := new(expvar.Int)
+	v.Set(int64(remaining))
+	rateLimitRemaining.Set(t.provider, v)
+
+	if remaining <= RateLimitLowRemaining {
+		log.Printf("rate limit of provider %s: %d requests remaining\n", t.provider, remaining)
+	}
+}
+
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	// github rejects requests over primary rate limit with 403
+	return resp.StatusCode == http.StatusForbidden &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+// retryAfter find time of waiting from `Retry-After` header in seconds or from time of rate limit reset
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date), true
+		}
+	}
+
+	value := resp.Header.Get("X-RateLimit-Reset")
+	if value == "" {
+		value = resp.Header.Get("RateLimit-Reset")
+	}
+	if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+		wait := time.Until(time.Unix(reset, 0))
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
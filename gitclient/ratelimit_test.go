@@ -0,0 +1,64 @@
+package gitclient
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		header map[string]string
+		found  bool
+		min    time.Duration
+		max    time.Duration
+	}{
+		{"seconds", map[string]string{"Retry-After": "30"}, true, 30 * time.Second, 30 * time.Second},
+		{"http date", map[string]string{"Retry-After": now.Add(time.Minute).UTC().Format(http.TimeFormat)}, true, 58 * time.Second, time.Minute},
+		{"github reset", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(now.Add(time.Minute).Unix(), 10)}, true, 58 * time.Second, time.Minute},
+		{"gitlab reset", map[string]string{"RateLimit-Reset": strconv.FormatInt(now.Add(10*time.Second).Unix(), 10)}, true, 8 * time.Second, 10 * time.Second},
+		{"reset in past", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)}, true, 0, 0},
+		{"invalid retry after uses reset", map[string]string{"Retry-After": "soon", "X-RateLimit-Reset": strconv.FormatInt(now.Unix(), 10)}, true, 0, 0},
+		{"invalid reset", map[string]string{"X-RateLimit-Reset": "soon"}, false, 0, 0},
+		{"no headers", map[string]string{}, false, 0, 0},
+	}
+	for _, test := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		for key, value := range test.header {
+			resp.Header.Set(key, value)
+		}
+		wait, found := retryAfter(resp)
+		if found != test.found {
+			t.Errorf("%s: retryAfter found %v, expected %v", test.name, found, test.found)
+		}
+		if wait < test.min || wait > test.max {
+			t.Errorf("%s: retryAfter = %v, expected between %v and %v", test.name, wait, test.min, test.max)
+		}
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	tests := []struct {
+		status  int
+		header  map[string]string
+		limited bool
+	}{
+		{http.StatusTooManyRequests, nil, true},
+		{http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0"}, true},
+		{http.StatusForbidden, map[string]string{"Retry-After": "60"}, true},
+		{http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "10"}, false},
+		{http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0"}, false},
+	}
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		for key, value := range test.header {
+			resp.Header.Set(key, value)
+		}
+		if limited := isRateLimited(resp); limited != test.limited {
+			t.Errorf("isRateLimited(%d, %v) = %v, expected %v", test.status, test.header, limited, test.limited)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package gitclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"demius.md/deployment-operator/api"
+)
+
+const (
+	// RegistryTimeout is timeout of one request to container registry
+	RegistryTimeout = 30 * time.Second
+	// RegistryTokenTTL is time of life of bearer token, when auth service does not declare `expires_in`
+	RegistryTokenTTL = 60 * time.Second
+	// registryTokenMargin is time before expiration, when bearer token is requested again
+	registryTokenMargin = 10 * time.Second
+)
+
+// manifestMediaTypes are accepted media types of image manifest, index is preferred for multi-arch images
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// RegistryClient load image tags from container registry via OCI distribution api
+type RegistryClient struct {
+	baseURL    string
+	token      TokenSource
+	httpclient *http.Client
+	authHosts  []string
+
+	tokensLock sync.Mutex
+	tokens     map[string]bearerToken // bearer tokens per scope
+}
+
+// bearerToken is token of auth service of registry
+type bearerToken struct {
+	token   string
+	expires time.Time
+}
+
+type registryTags struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type registryToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ConnectRegistry connects to container registry, url may be used for registry without tls, e.g. `http://localhost:5000`.
+// Secret is `username:password` for basic auth or token, which is used as bearer token
+func ConnectRegistry(conf Config) *RegistryClient {
+	return &RegistryClient{
+		baseURL:    conf.baseURL(),
+		token:      conf.Token,
+		httpclient: &http.Client{Timeout: RegistryTimeout, Transport: NewRateLimitTransport(conf.Provider, conf.Transport)},
+		authHosts:  conf.AuthHosts,
+		tokens:     make(map[string]bearerToken),
+	}
+}
+
+// ProviderName return docker registry provider name
+func (c *RegistryClient) ProviderName() string {
+	return "registry"
+}
+
+// LoadImageTag load tag of docker image for project, image name in registry is `group/project`.
+// Tags in registry are not ordered by date, so tags are always ordered by semantic version
+func (c *RegistryClient) LoadImageTag(groupName, projectName, mode string, policy *TagPolicy) (*api.ReleaseInfo, error) {
+	selector, err := newTagSelector(policy, mode)
+	if err != nil {
+		return nil, err
+	}
+	selector.orderBySemver()
+
+	name := strings.ToLower(groupName + "/" + projectName)
+
+	next := "/v2/" + name + "/tags/list?n=" + strconv.Itoa(ReleasesPageSize)
+	for next != "" {
+		resp, err := c.do(http.MethodGet, next, name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of image `%s` error: %v", name, err)
+		}
+
+		var tags registryTags
+		err = decodeResponse(resp, &tags)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of image `%s` error: %v", name, err)
+		}
+
+		page := make([]Release, len(tags.Tags))
+		for i, tag := range tags.Tags {
+			page[i] = Release{
+				Tag:  tag,
+				Info: &api.ReleaseInfo{ImageTag: tag},
+			}
+		}
+
+		if selector.Add(page) {
+			break
+		}
+		next = nextLink(resp.Header.Get("Link"))
+	}
+
+	selected := selector.Selected()
+	if selected == nil {
+		return nil, fmt.Errorf("no tag of image `%s` matches tag policy for mode %s", name, mode)
+	}
+
+	digest, err := c.resolveDigest(name, selected.Tag)
+	if err != nil {
+		return nil, err
+	}
+	selected.Info.ImageDigest = digest
+	return selected.Info, nil
+}
+
+// resolveDigest find digest of image manifest, so image may be pinned as `image@sha256:...`
+func (c *RegistryClient) resolveDigest(name, tag string) (string, error) {
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	path := "/v2/" + name + "/manifests/" + tag
+
+	resp, err := c.do(http.MethodHead, path, name, header)
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of image `%s:%s` error: %v", name, tag, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+			return digest, nil
+		}
+	}
+
+	// some registries do not return digest in response of HEAD request, so it is calculated from manifest
+	resp, err = c.do(http.MethodGet, path, name, header)
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of image `%s:%s` error: %v", name, tag, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve digest of image `%s:%s` error: %s", name, tag, resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("read manifest of image `%s:%s` error: %v", name, tag, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// do send request to registry, bearer token is requested from auth service of registry on demand
+func (c *RegistryClient) do(method, path, name string, header http.Header) (*http.Response, error) {
+	scope := "repository:" + name + ":pull"
+	requestURL, err := c.resolveURL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(method, requestURL, scope, header)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, fmt.Errorf("unauthorized in registry %s", c.baseURL)
+	}
+	if err := c.fetchToken(challenge[len("bearer "):], scope); err != nil {
+		return nil, err
+	}
+	return c.send(method, requestURL, scope, header)
+}
+
+func (c *RegistryClient) send(method, requestURL, scope string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if !c.sameHost(req.URL) {
+		// absolute link of registry may point to other host, credentials are sent only to registry
+		return c.httpclient.Do(req)
+	}
+
+	c.tokensLock.Lock()
+	token, ok := c.tokens[scope]
+	c.tokensLock.Unlock()
+
+	if ok && time.Now().Before(token.expires) {
+		req.Header.Set("Authorization", "Bearer "+token.token)
+		return c.httpclient.Do(req)
+	}
+
+	secret, err := c.secret()
+	if err != nil {
+		return nil, err
+	}
+	if user, password, ok := credentials(secret); ok {
+		req.SetBasicAuth(user, password)
+	} else if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	return c.httpclient.Do(req)
+}
+
+// resolveURL resolve path or link of registry against url of registry
+func (c *RegistryClient) resolveURL(path string) (string, error) {
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") {
+		return c.baseURL + path, nil
+	}
+	baseURL, err := url.Parse(c.baseURL + "/")
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid link `%s` of registry %s: %v", path, c.baseURL, err)
+	}
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+// sameHost report whether url is on host of registry
+func (c *RegistryClient) sameHost(requestURL *url.URL) bool {
+	baseURL, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(requestURL.Scheme, baseURL.Scheme) && strings.EqualFold(requestURL.Host, baseURL.Host)
+}
+
+// fetchToken request bearer token from auth service declared in challenge of registry
+func (c *RegistryClient) fetchToken(challenge, scope string) error {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("registry %s does not declare auth realm", c.baseURL)
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	trusted, err := c.trustedRealm(realm)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		log.Printf("credentials are not sent to auth realm %s of registry %s, host of realm is not trusted\n", realm, c.baseURL)
+	} else {
+		secret, err := c.secret()
+		if err != nil {
+			return err
+		}
+		if user, password, ok := credentials(secret); ok {
+			req.SetBasicAuth(user, password)
+		}
+	}
+
+	resp, err := c.httpclient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request registry token error: %v", err)
+	}
+	var token registryToken
+	if err := decodeResponse(resp, &token); err != nil {
+		return fmt.Errorf("request registry token error: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return fmt.Errorf("auth service of registry %s returned empty token", c.baseURL)
+	}
+
+	ttl := RegistryTokenTTL
+	if token.ExpiresIn > 0 {
+		ttl = time.Duration(token.ExpiresIn) * time.Second
+	}
+	if ttl > 2*registryTokenMargin {
+		ttl -= registryTokenMargin
+	}
+
+	c.tokensLock.Lock()
+	c.tokens[scope] = bearerToken{token.Token, time.Now().Add(ttl)}
+	c.tokensLock.Unlock()
+	return nil
+}
+
+// trustedRealm report whether credentials may be sent to auth service: realm must be on host of registry
+// or on one of configured auth hosts
+func (c *RegistryClient) trustedRealm(realm string) (bool, error) {
+	realmURL, err := url.Parse(realm)
+	if err != nil {
+		return false, fmt.Errorf("invalid auth realm `%s` of registry %s: %v", realm, c.baseURL, err)
+	}
+	baseURL, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(realmURL.Host, baseURL.Host) {
+		return true, nil
+	}
+	for _, host := range c.authHosts {
+		if strings.EqualFold(realmURL.Host, host) || strings.EqualFold(realmURL.Hostname(), host) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *RegistryClient) secret() (string, error) {
+	if c.token == nil {
+		return "", nil
+	}
+	return c.token.Token()
+}
+
+// credentials split secret `username:password` for basic auth
+func credentials(secret string) (string, string, bool) {
+	idx := strings.Index(secret, ":")
+	if idx < 0 {
+		return "", "", false
+	}
+	return secret[:idx], secret[idx+1:], true
+}
+
+// parseChallenge parse params of `WWW-Authenticate` header, e.g. `realm="https://auth.io/token",service="registry.io"`
+func parseChallenge(challenge string) map[string]string {
+	params := make(map[string]string)
+	for s := strings.TrimSpace(challenge); s != ""; {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		params[key] = value
+		s = strings.TrimLeft(s, ", ")
+	}
+	return params
+}
+
+// nextLink find url of next page in `Link` header, e.g. `</v2/name/tags/list?last=v1&n=50>; rel="next"`
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		start, end := strings.Index(part, "<"), strings.Index(part, ">")
+		if start < 0 || end < start || !strings.Contains(part[end:], `rel="next"`) {
+			continue
+		}
+		return part[start+1 : end]
+	}
+	return ""
+}
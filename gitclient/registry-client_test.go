@@ -0,0 +1,245 @@
+package gitclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRegistry is stand-in of container registry with token auth service
+type fakeRegistry struct {
+	*httptest.Server
+
+	lock        sync.Mutex
+	tokenCalls  int
+	basicAuth   []string
+	headDigest  bool
+	manifest    string
+	tagRequests []string
+}
+
+func newFakeRegistry(t *testing.T, headDigest bool) *fakeRegistry {
+	r := &fakeRegistry{headDigest: headDigest, manifest: `{"schemaVersion":2}`}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		r.lock.Lock()
+		r.tokenCalls++
+		user, password, _ := req.BasicAuth()
+		r.basicAuth = append(r.basicAuth, user+":"+password)
+		r.lock.Unlock()
+
+		if req.URL.Query().Get("scope") != "repository:group/project:pull" || req.URL.Query().Get("service") != "fake" {
+			t.Errorf("unexpected token request: %s", req.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"token":"tok","expires_in":300}`)
+	})
+
+	mux.HandleFunc("/v2/group/project/tags/list", func(w http.ResponseWriter, req *http.Request) {
+		if !r.authorized(w, req) {
+			return
+		}
+		r.lock.Lock()
+		r.tagRequests = append(r.tagRequests, req.URL.RawQuery)
+		r.lock.Unlock()
+
+		if req.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/group/project/tags/list?last=v1.2.0&n=50>; rel="next"`)
+			fmt.Fprint(w, `{"name":"group/project","tags":["v1.0.0","v1.2.0","latest"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"name":"group/project","tags":["v1.10.0","v2.0.0-rc1"]}`)
+	})
+
+	mux.HandleFunc("/v2/group/project/manifests/", func(w http.ResponseWriter, req *http.Request) {
+		if !r.authorized(w, req) {
+			return
+		}
+		if req.Method == http.MethodHead {
+			if r.headDigest {
+				w.Header().Set("Docker-Content-Digest", "sha256:head")
+			}
+			return
+		}
+		fmt.Fprint(w, r.manifest)
+	})
+
+	r.Server = httptest.NewServer(mux)
+	return r
+}
+
+// authorized check bearer token and send challenge of token auth, when token is missing
+func (r *fakeRegistry) authorized(w http.ResponseWriter, req *http.Request) bool {
+	if req.Header.Get("Authorization") == "Bearer tok" {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:group/project:pull"`, r.URL))
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+func TestRegistryLoadImageTag(t *testing.T) {
+	registry := newFakeRegistry(t, true)
+	defer registry.Close()
+
+	client := ConnectRegistry(Config{Provider: "registry.test", URL: registry.URL, Token: StaticToken("user:secret")})
+
+	release, err := client.LoadImageTag("Group", "project", "prod", nil)
+	if err != nil {
+		t.Fatalf("LoadImageTag error: %v", err)
+	}
+	if release.ImageTag != "v1.10.0" {
+		t.Errorf("tag = %s, expected v1.10.0", release.ImageTag)
+	}
+	if release.ImageDigest != "sha256:head" {
+		t.Errorf("digest = %s, expected digest of HEAD response", release.ImageDigest)
+	}
+	if len(registry.tagRequests) != 2 || registry.tagRequests[1] != "last=v1.2.0&n=50" {
+		t.Errorf("pages of tags are not loaded by Link header: %v", registry.tagRequests)
+	}
+	if registry.tokenCalls != 1 {
+		t.Errorf("token is requested %d times, expected once", registry.tokenCalls)
+	}
+	if !reflect.DeepEqual(registry.basicAuth, []string{"user:secret"}) {
+		t.Errorf("credentials of token request = %v", registry.basicAuth)
+	}
+
+	// expired token is requested again
+	client.tokens["repository:group/project:pull"] = bearerToken{"tok", time.Now().Add(-time.Second)}
+	release, err = client.LoadImageTag("group", "project", "devel", nil)
+	if err != nil {
+		t.Fatalf("LoadImageTag error: %v", err)
+	}
+	if release.ImageTag != "v2.0.0-rc1" {
+		t.Errorf("tag in devel mode = %s, expected prerelease v2.0.0-rc1", release.ImageTag)
+	}
+	if registry.tokenCalls != 2 {
+		t.Errorf("expired token is not requested again, token calls: %d", registry.tokenCalls)
+	}
+}
+
+func TestRegistryDigestOfManifest(t *testing.T) {
+	registry := newFakeRegistry(t, false)
+	defer registry.Close()
+
+	client := ConnectRegistry(Config{Provider: "registry.test", URL: registry.URL})
+
+	digest, err := client.resolveDigest("group/project", "v1.10.0")
+	if err != nil {
+		t.Fatalf("resolveDigest error: %v", err)
+	}
+	hash := sha256.Sum256([]byte(registry.manifest))
+	if expected := "sha256:" + hex.EncodeToString(hash[:]); digest != expected {
+		t.Errorf("digest = %s, expected %s", digest, expected)
+	}
+	if !reflect.DeepEqual(registry.basicAuth, []string{":"}) {
+		t.Errorf("anonymous token is requested with credentials: %v", registry.basicAuth)
+	}
+}
+
+func TestRegistryTrustedRealm(t *testing.T) {
+	client := ConnectRegistry(Config{URL: "https://registry.io:5000", AuthHosts: []string{"auth.io"}})
+
+	tests := []struct {
+		realm   string
+		trusted bool
+	}{
+		{"https://registry.io:5000/token", true},
+		{"https://REGISTRY.io:5000/token", true},
+		{"https://registry.io/token", false},
+		{"https://auth.io/token", true},
+		{"https://auth.io:8443/token", true},
+		{"https://evil.io/token", false},
+	}
+	for _, test := range tests {
+		trusted, err := client.trustedRealm(test.realm)
+		if err != nil {
+			t.Errorf("trustedRealm(%s) error: %v", test.realm, err)
+		}
+		if trusted != test.trusted {
+			t.Errorf("trustedRealm(%s) = %v, expected %v", test.realm, trusted, test.trusted)
+		}
+	}
+}
+
+func TestRegistryResolveURL(t *testing.T) {
+	client := ConnectRegistry(Config{URL: "https://registry.io:5000"})
+
+	tests := []struct {
+		path     string
+		resolved string
+		sameHost bool
+	}{
+		{"/v2/a/tags/list?last=v1", "https://registry.io:5000/v2/a/tags/list?last=v1", true},
+		{"https://REGISTRY.io:5000/v2/a/tags/list", "https://REGISTRY.io:5000/v2/a/tags/list", true},
+		{"https://evil.io/v2/a/tags/list", "https://evil.io/v2/a/tags/list", false},
+		{"//evil.io/v2/a/tags/list", "https://evil.io/v2/a/tags/list", false},
+		{"http://registry.io:5000/v2/a/tags/list", "http://registry.io:5000/v2/a/tags/list", false},
+		{"tags/list?last=v1", "https://registry.io:5000/tags/list?last=v1", true},
+	}
+	for _, test := range tests {
+		resolved, err := client.resolveURL(test.path)
+		if err != nil {
+			t.Errorf("resolveURL(%s) error: %v", test.path, err)
+			continue
+		}
+		if resolved != test.resolved {
+			t.Errorf("resolveURL(%s) = %s, expected %s", test.path, resolved, test.resolved)
+		}
+		requestURL, _ := url.Parse(resolved)
+		if sameHost := client.sameHost(requestURL); sameHost != test.sameHost {
+			t.Errorf("sameHost(%s) = %v, expected %v", resolved, sameHost, test.sameHost)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		challenge string
+		params    map[string]string
+	}{
+		{
+			`realm="https://auth.io/token",service="registry.io"`,
+			map[string]string{"realm": "https://auth.io/token", "service": "registry.io"},
+		},
+		{
+			`Realm="https://auth.io/token", scope="repository:a/b:pull,push"`,
+			map[string]string{"realm": "https://auth.io/token", "scope": "repository:a/b:pull,push"},
+		},
+		{
+			`realm=https://auth.io/token,service=registry.io`,
+			map[string]string{"realm": "https://auth.io/token", "service": "registry.io"},
+		},
+		{`realm="unterminated`, map[string]string{"realm": "unterminated"}},
+		{``, map[string]string{}},
+	}
+	for _, test := range tests {
+		if params := parseChallenge(test.challenge); !reflect.DeepEqual(params, test.params) {
+			t.Errorf("parseChallenge(%s) = %v, expected %v", test.challenge, params, test.params)
+		}
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		link string
+		next string
+	}{
+		{`</v2/a/tags/list?last=v1&n=50>; rel="next"`, "/v2/a/tags/list?last=v1&n=50"},
+		{`</v2/a/tags/list?n=50>; rel="prev", </v2/a/tags/list?last=v2>; rel="next"`, "/v2/a/tags/list?last=v2"},
+		{`</v2/a/tags/list?n=50>; rel="prev"`, ""},
+		{``, ""},
+	}
+	for _, test := range tests {
+		if next := nextLink(test.link); next != test.next {
+			t.Errorf("nextLink(%s) = %s, expected %s", test.link, next, test.next)
+		}
+	}
+}
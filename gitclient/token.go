@@ -0,0 +1,62 @@
+package gitclient
+
+import (
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenSource provide secret token of provider. Token is requested for every request to provider,
+// so rotated token is used without restart
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken is token declared in config
+type StaticToken string
+
+// Token implements TokenSource
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// tokenTransport set secret token into header of every request
+type tokenTransport struct {
+	header string
+	prefix string
+	source TokenSource
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	// request must not be modified by transport
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.prefix+token)
+	return t.base.RoundTrip(req)
+}
+
+// withToken wrap transport for setting token into header, transport is not wrapped without token source
+func withToken(base http.RoundTripper, source TokenSource, header, prefix string) http.RoundTripper {
+	if source == nil {
+		return base
+	}
+	return &tokenTransport{header, prefix, source, base}
+}
+
+// oauth2Source adapt token source for oauth2 transport
+type oauth2Source struct {
+	source TokenSource
+}
+
+func (s oauth2Source) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token}, nil
+}
@@ -5,8 +5,10 @@ go 1.16
 // https://github.com/kubernetes/client-go/blob/master/INSTALL.md#go-modules
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/golang/protobuf v1.4.3
 	github.com/google/go-github/v31 v31.0.0
+	github.com/imdario/mergo v0.3.6 // indirect
 	github.com/xanzy/go-gitlab v0.47.0
 	golang.org/x/oauth2 v0.0.0-20210313182246-cd4f82c27b84
 	google.golang.org/grpc v1.36.0
@@ -14,4 +16,5 @@ require (
 	k8s.io/api v0.20.4
 	k8s.io/apimachinery v0.20.4
 	k8s.io/client-go v0.20.4
+	sigs.k8s.io/yaml v1.2.0
 )
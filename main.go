@@ -1,11 +1,15 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"expvar"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -18,18 +22,103 @@ import (
 // MaxMessageSize maximum message size of GRPC
 const MaxMessageSize = 1024 * 1024
 
+// DefaultConfigPath is path of config, when it is not declared by flag or env
+const DefaultConfigPath = "/etc/deploy/config.yaml"
+
+// options override settings of config, declared by command-line flags or environment variables
+type options struct {
+	configPath     string
+	listenAddress  string
+	port           int
+	templates      string
+	kustomizations string
+	kubeconfig     string
+	kubeContext    string
+	checkConfig    bool
+}
+
+func parseOptions() options {
+	var opts options
+	flag.StringVar(&opts.configPath, "config", getEnv("DEPLOY_CONFIG", DefaultConfigPath), "path of config, env DEPLOY_CONFIG")
+	flag.StringVar(&opts.listenAddress, "listen-address", os.Getenv("DEPLOY_LISTEN_ADDRESS"), "listen address of grpc server, env DEPLOY_LISTEN_ADDRESS")
+	flag.IntVar(&opts.port, "port", getEnvInt("DEPLOY_PORT"), "port of grpc server, env DEPLOY_PORT")
+	flag.StringVar(&opts.templates, "templates", os.Getenv("DEPLOY_TEMPLATES"), "directory of deploy templates, env DEPLOY_TEMPLATES")
+	flag.StringVar(&opts.kustomizations, "kustomizations", os.Getenv("DEPLOY_KUSTOMIZATIONS"), "directory of kustomizations, env DEPLOY_KUSTOMIZATIONS")
+	flag.StringVar(&opts.kubeconfig, "kubeconfig", "", "path of kubeconfig of cluster declared by `kube`, env KUBECONFIG is used by default")
+	flag.StringVar(&opts.kubeContext, "context", os.Getenv("DEPLOY_KUBE_CONTEXT"), "context of kubeconfig, env DEPLOY_KUBE_CONTEXT")
+	flag.BoolVar(&opts.checkConfig, "check-config", false, "validate config, templates and kustomizations, then exit")
+	flag.Parse()
+	return opts
+}
+
+func getEnv(name, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid value of %s: %v", name, err)
+	}
+	return i
+}
+
+// apply override settings of config by declared options
+func (o options) apply(config *service.DeployConfig) {
+	if o.listenAddress != "" {
+		config.ListenAddress = o.listenAddress
+	}
+	if o.port != 0 {
+		config.ServerPort = o.port
+	}
+	if o.templates != "" {
+		config.DeployTemplates = o.templates
+	}
+	if o.kustomizations != "" {
+		config.Kustomizations = o.kustomizations
+	}
+	if o.kubeconfig != "" {
+		config.Kube.Kubeconfig = o.kubeconfig
+	}
+	if o.kubeContext != "" {
+		config.Kube.Context = o.kubeContext
+	}
+}
+
 func main() {
+	args := parseOptions()
+
 	executablePath := utils.ExecutableDir()
 	homePath := utils.UserHomeDir()
 	fmt.Println("executable path: " + executablePath)
 	fmt.Println("      home path: " + homePath)
 
-	// disable check of http cert because wrong acc cert and Marina gitlab
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	config, err := service.LoadDeployConfig(args.configPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	args.apply(config)
 
-	config := service.LoadDeployConfig("/etc/deploy/config.yaml")
+	if args.checkConfig {
+		if err := service.CheckConfig(config); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("config %s is valid\n", args.configPath)
+		return
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalln(err)
+	}
 
-	listenURL := fmt.Sprintf("0.0.0.0:%v", config.ServerPort)
+	listenURL := net.JoinHostPort(config.ListenAddress, strconv.Itoa(config.ServerPort))
 	listener, err := net.Listen("tcp", listenURL)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
@@ -44,14 +133,34 @@ func main() {
 		log.Println("Key-file: " + certs.KeyFile + "; certs-file: " + certs.CertFile)
 	}
 
-	creds, err := credentials.NewServerTLSFromFile(certs.CertFile, certs.KeyFile)
+	tlsConfig, err := config.Auth.TLSConfig(certs)
 	if err != nil {
 		log.Fatalf("Failed to generate credentials %v", err)
 	}
-	opts = []grpc.ServerOption{grpc.Creds(creds), grpc.MaxRecvMsgSize(MaxMessageSize)}
+	opts = []grpc.ServerOption{grpc.Creds(credentials.NewTLS(tlsConfig)), grpc.MaxRecvMsgSize(MaxMessageSize)}
+
+	server := service.NewServer(config)
+
+	if config.Auth.Enabled() {
+		interceptor, err := server.AuthInterceptor(config.Auth)
+		if err != nil {
+			log.Fatalf("Failed to create authentication of clients %v", err)
+		}
+		opts = append(opts, grpc.UnaryInterceptor(interceptor))
+	} else {
+		log.Println("grpc api is not protected, identities of clients are not declared")
+	}
 
 	grpcServer := grpc.NewServer(opts...)
-	api.RegisterDeploymentServer(grpcServer, service.NewServer(config.DeployTemplates, config.Kustomizations, config.Providers))
+	api.RegisterDeploymentServer(grpcServer, server)
+
+	if config.Webhook.Port != 0 {
+		go serveWebhooks(config.ListenAddress, config.Webhook, certs, server)
+	}
+	if config.Metrics.Port != 0 {
+		go serveMetrics(config.Metrics)
+	}
+	server.StartPoller(context.Background(), config.Poller)
 
 	log.Printf("Starting deploy-operator at `%s`\n", listenURL)
 
@@ -61,3 +170,31 @@ func main() {
 
 	log.Println("grpc server stopped")
 }
+
+// serveWebhooks start http listener of webhooks from git providers
+func serveWebhooks(listenAddress string, conf service.WebhookConf, certs service.CertsConf, server service.Server) {
+	mux := http.NewServeMux()
+	mux.Handle(service.WebhookPath, server.WebhookHandler(conf))
+
+	listenURL := net.JoinHostPort(listenAddress, strconv.Itoa(conf.Port))
+	log.Printf("Starting webhooks listener at `%s`\n", listenURL)
+
+	var err error
+	if conf.TLS {
+		err = http.ListenAndServeTLS(listenURL, certs.CertFile, certs.KeyFile, mux)
+	} else {
+		err = http.ListenAndServe(listenURL, mux)
+	}
+	log.Fatalf("failed to serve webhooks: %v", err)
+}
+
+// serveMetrics start http listener of metrics, metrics are served at `/debug/vars`
+func serveMetrics(conf service.MetricsConf) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	listenURL := net.JoinHostPort(conf.ListenAddress, strconv.Itoa(conf.Port))
+	log.Printf("Starting metrics listener at `%s`\n", listenURL)
+
+	log.Fatalf("failed to serve metrics: %v", http.ListenAndServe(listenURL, mux))
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager is name of field manager used by server-side apply
+const FieldManager = "deployment-operator"
+
+// ApplyConflictError is returned when server-side apply has conflicts with other field managers
+type ApplyConflictError struct {
+	Object    string
+	Conflicts []string
+}
+
+func (e *ApplyConflictError) Error() string {
+	return fmt.Sprintf("apply of `%s` has %d conflicts with other field managers", e.Object, len(e.Conflicts))
+}
+
+// DecodeManifest decode multi-document yaml manifest into unstructured objects
+func DecodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
+
+	var objects []*unstructured.Unstructured
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("can not decode manifest: %v", err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("manifest object must have kind and name")
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetKind() + "/" + obj.GetName()
+	}
+	return obj.GetKind() + "/" + obj.GetNamespace() + "." + obj.GetName()
+}
+
+// resourceFor find dynamic client for kind of object
+func (c *cluster) resourceFor(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// kind may be registered after cache was filled, e.g. new CRD
+		c.mapper.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not find resource for kind `%s`: %v", gvk.String(), err)
+	}
+	c.kinds.Store(mapping.Resource, mapping)
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+	return c.dynamic.Resource(mapping.Resource), nil
+}
+
+// typedObject convert typed object into unstructured object for server-side apply,
+// fields populated by k8s are removed, so they are not owned by operator
+func typedObject(obj interface{}, apiVersion, kind string) (*unstructured.Unstructured, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("can not convert %s to unstructured object: %v", kind, err)
+	}
+	u := &unstructured.Unstructured{Object: fields}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	return u, nil
+}
+
+// findObject find allready existed object with kind, namespace and name of obj
+func (c *cluster) findObject(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	log.Println("find " + objectName(obj))
+	resource, err := c.resourceFor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get `%s`, got error '%v'", objectName(obj), err)
+	}
+	return live, nil
+}
+
+// applyObject create or update object via server-side apply
+func (c *cluster) applyObject(ctx context.Context, obj *unstructured.Unstructured, force bool) (*unstructured.Unstructured, error) {
+	resource, err := c.resourceFor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal `%s`: %v", objectName(obj), err)
+	}
+
+	applied, err := resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		if errors.IsConflict(err) {
+			return nil, applyConflictError(obj, err)
+		}
+		return nil, fmt.Errorf("apply `%s` error '%v'", objectName(obj), err)
+	}
+	return applied, nil
+}
+
+// removeObject remove object from k8s
+func (c *cluster) removeObject(ctx context.Context, obj *unstructured.Unstructured) error {
+	resource, err := c.resourceFor(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := resource.Delete(ctx, obj.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete `%s` error `%v`", objectName(obj), err)
+	}
+	return nil
+}
+
+func applyConflictError(obj *unstructured.Unstructured, err error) error {
+	conflictErr := &ApplyConflictError{Object: objectName(obj)}
+
+	if status, ok := err.(errors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			conflictErr.Conflicts = append(conflictErr.Conflicts, cause.Field+": "+cause.Message)
+		}
+	}
+	if len(conflictErr.Conflicts) == 0 {
+		conflictErr.Conflicts = []string{err.Error()}
+	}
+	return conflictErr
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTypedObject(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "dev"},
+		Status:     appsv1.DeploymentStatus{Replicas: 1},
+	}
+	service := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "dev"},
+		Spec:       apiv1.ServiceSpec{Ports: []apiv1.ServicePort{{Port: 80}}},
+	}
+	tests := []struct {
+		name       string
+		obj        interface{}
+		apiVersion string
+		kind       string
+		path       []string
+		found      bool
+	}{
+		{name: "kind is set", obj: deployment, apiVersion: "apps/v1", kind: "Deployment", path: []string{"kind"}, found: true},
+		{name: "status is removed", obj: deployment, apiVersion: "apps/v1", kind: "Deployment", path: []string{"status"}},
+		{name: "creation timestamp is removed", obj: deployment, apiVersion: "apps/v1", kind: "Deployment", path: []string{"metadata", "creationTimestamp"}},
+		{name: "cluster ip is not applied", obj: service, apiVersion: "v1", kind: "Service", path: []string{"spec", "clusterIP"}},
+		{name: "ports are applied", obj: service, apiVersion: "v1", kind: "Service", path: []string{"spec", "ports"}, found: true},
+	}
+	for _, test := range tests {
+		obj, err := typedObject(test.obj, test.apiVersion, test.kind)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if obj.GetAPIVersion() != test.apiVersion || obj.GetKind() != test.kind {
+			t.Errorf("%s: object has type %s %s, expected %s %s", test.name, obj.GetAPIVersion(), obj.GetKind(), test.apiVersion, test.kind)
+		}
+		if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, test.path...); found != test.found {
+			t.Errorf("%s: field %v found = %v, expected %v", test.name, test.path, found, test.found)
+		}
+	}
+}
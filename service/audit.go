@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/peer"
+	apiv1 "k8s.io/api/core/v1"
+
+	"demius.md/deployment-operator/api"
+)
+
+// AuditConf contains settings of audit log of deployments
+type AuditConf struct {
+	File   string `yaml:"file"`   // append-only JSON lines file, audit log is not written without file
+	Events bool   `yaml:"events"` // create k8s events about actions with objects of services
+}
+
+// actorKey is key of name of initiator of deployment in context, when grpc client is not authenticated
+type actorKey struct{}
+
+// auditLog write records about deployments
+type auditLog struct {
+	lock   sync.Mutex
+	file   *os.File
+	events bool
+}
+
+// deployRecord is audit record of deployment call
+type deployRecord struct {
+	Time     string `json:"time"`
+	Event    string `json:"event"`
+	Actor    string `json:"actor"`
+	Path     string `json:"path"`
+	Mode     string `json:"mode"`
+	Recreate bool   `json:"recreate"`
+	Prune    bool   `json:"prune"`
+	Cluster  string `json:"cluster,omitempty"`
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+	Peer     string `json:"peer,omitempty"`
+}
+
+// outcomes of deployment call in audit log
+const (
+	auditAccepted        = "accepted"
+	auditDenied          = "denied"
+	auditUnauthenticated = "unauthenticated"
+)
+
+// serviceRecord is audit record of action with service
+type serviceRecord struct {
+	Time      string `json:"time"`
+	Event     string `json:"event"`
+	Actor     string `json:"actor"`
+	Path      string `json:"path"`
+	Mode      string `json:"mode,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Action    string `json:"action,omitempty"`
+	Error     string `json:"error,omitempty"`
+	BeforeTag string `json:"before_tag,omitempty"`
+	AfterTag  string `json:"after_tag,omitempty"`
+}
+
+// serviceAudit contains state of service before deployment, filled by handleKustomization
+type serviceAudit struct {
+	namespace string
+	before    *liveRelease
+	handler   *baseHandler
+	manifest  string // hash of rendered manifest of main object, written into history
+}
+
+// newAuditLog open audit log file for appending
+func newAuditLog(conf AuditConf) (*auditLog, error) {
+	a := &auditLog{events: conf.Events}
+	if conf.File == "" {
+		return a, nil
+	}
+	file, err := os.OpenFile(conf.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("can not open audit log: %v", err)
+	}
+	a.file = file
+	return a, nil
+}
+
+// enabled report whether state of services must be tracked for audit
+func (a *auditLog) enabled() bool {
+	return a.file != nil || a.events
+}
+
+// write append record to audit log file as single JSON line
+func (a *auditLog) write(record interface{}) {
+	if a.file == nil {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("can not marshal audit record: %v\n", err)
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		log.Printf("can not write audit record: %v\n", err)
+	}
+}
+
+// withActor set name of initiator of deployment, which is not grpc client: webhook or poller
+func withActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, actorKey{}, name)
+}
+
+// actor return name of initiator of deployment: identity of grpc client or actor of context
+func actor(ctx context.Context) string {
+	if id := clientIdentity(ctx); id != nil {
+		return id.name
+	}
+	if name, ok := ctx.Value(actorKey{}).(string); ok {
+		return name
+	}
+	return "anonymous"
+}
+
+// auditDeploy record deployment call with outcome of authentication and authorization
+func (s *deploymentServer) auditDeploy(ctx context.Context, request *api.Request, outcome string, err error) {
+	record := &deployRecord{
+		Time:     time.Now().Format(time.RFC3339),
+		Event:    "deploy",
+		Actor:    actor(ctx),
+		Path:     request.Path,
+		Mode:     serverModeName(request.Mode),
+		Recreate: request.Recreate,
+		Prune:    request.Prune,
+		Cluster:  request.Cluster,
+		Outcome:  outcome,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		record.Peer = p.Addr.String()
+	}
+	s.audit.write(record)
+}
+
+// auditService record action with service and create k8s event on main object of service
+func (s *deploymentServer) auditService(ctx context.Context, serviceInfo *api.ServiceInfo, mode api.ServerMode, state *serviceAudit) {
+	record := &serviceRecord{
+		Time:    time.Now().Format(time.RFC3339),
+		Event:   "service",
+		Actor:   actor(ctx),
+		Path:    serviceInfo.Path,
+		Mode:    serverModeName(mode),
+		Cluster: serviceInfo.Cluster,
+		Kind:    serviceInfo.GetServiceId().GetKind(),
+		Name:    serviceInfo.GetServiceId().GetPackage(),
+		Error:   serviceInfo.GetErrorDescription(),
+	}
+	if record.Error == "" {
+		record.Action = serviceInfo.GetAction().String()
+	}
+	if state != nil {
+		record.Namespace = state.namespace
+		if state.before != nil {
+			record.BeforeTag = state.before.tag
+		}
+	}
+
+	switch {
+	case record.Error != "":
+		record.AfterTag = record.BeforeTag
+	case serviceInfo.GetAction() != api.Action_Removed:
+		record.AfterTag = serviceInfo.GetRelease().GetImageTag()
+	}
+	s.audit.write(record)
+
+	if !s.audit.events || state == nil || state.handler == nil {
+		return
+	}
+	live := state.before
+	if live == nil && record.Error == "" && serviceInfo.GetAction() != api.Action_Removed {
+		// object is created by deployment
+		live, _ = s.findLiveRelease(*state.handler)
+	}
+	if live == nil {
+		return
+	}
+	if record.Error != "" {
+		live.cluster.recordEvent(ctx, live.ref, apiv1.EventTypeWarning, "DeployFailed",
+			fmt.Sprintf("deployment of release %s by %s failed: %s", serviceInfo.GetRelease().GetImageTag(), record.Actor, record.Error))
+		return
+	}
+	live.cluster.recordEvent(ctx, live.ref, apiv1.EventTypeNormal, "Deployed",
+		fmt.Sprintf("%s by %s: release %s -> %s", record.Action, record.Actor, record.BeforeTag, record.AfterTag))
+}
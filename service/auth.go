@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"demius.md/deployment-operator/api"
+	"demius.md/deployment-operator/gitclient"
+	"demius.md/deployment-operator/utils"
+)
+
+// AllowAll is value of paths, namespaces, clusters or modes of identity, which allows all of them
+const AllowAll = "*"
+
+// AuthConf contains settings of authentication of grpc clients, api is not protected when identities are not declared
+type AuthConf struct {
+	ClientCAFile string              `yaml:"client-ca-file"` // CA of client certificates, common name of certificate is name of identity
+	Identities   map[string]Identity `yaml:"identities"`     // identities of clients by name
+}
+
+// Identity is client of grpc api with scope of allowed deployments. Empty scope allows nothing, `*` allows all
+type Identity struct {
+	Token      string        `yaml:"token"`      // bearer token of client, client may be authenticated by certificate only
+	TokenFile  string        `yaml:"token-file"` // file with bearer token
+	TokenEnv   string        `yaml:"token-env"`  // environment variable with bearer token
+	TokenRef   *SecretKeyRef `yaml:"token-ref"`  // key of k8s secret with bearer token
+	Paths      []string      `yaml:"paths"`      // allowed prefixes of kustomization paths
+	Namespaces []string      `yaml:"namespaces"` // allowed namespaces of kustomizations
+	Clusters   []string      `yaml:"clusters"`   // allowed clusters of deployment
+	Modes      []string      `yaml:"modes"`      // allowed server modes: devel, prod
+}
+
+// identityKey is key of authenticated identity in context of request
+type identityKey struct{}
+
+// identity is authenticated client
+type identity struct {
+	name string
+	conf Identity
+}
+
+// Enabled report whether grpc api is protected
+func (c AuthConf) Enabled() bool {
+	return len(c.Identities) > 0
+}
+
+// Validate check identities and CA of client certificates
+func (c AuthConf) Validate() error {
+	if c.ClientCAFile != "" && !utils.FileExists(c.ClientCAFile) {
+		return fmt.Errorf("client-ca-file %s does not exist", c.ClientCAFile)
+	}
+	for name, conf := range c.Identities {
+		source, err := conf.tokenSource(nil)
+		if err != nil {
+			return fmt.Errorf("identity %s: %v", name, err)
+		}
+		if source == nil && c.ClientCAFile == "" {
+			return fmt.Errorf("identity %s: token or client-ca-file must be declared", name)
+		}
+		for _, mode := range conf.Modes {
+			if !validMode(mode, false) && mode != AllowAll {
+				return fmt.Errorf("identity %s: mode must be devel, prod or *, actual: `%s`", name, mode)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Identity) tokenSource(clientset kubernetes.Interface) (gitclient.TokenSource, error) {
+	return newTokenSource("token", c.Token, c.TokenFile, c.TokenEnv, c.TokenRef, clientset)
+}
+
+// TLSConfig create tls settings of grpc server. Client certificates are verified, when CA of clients is declared,
+// clients without certificate may be authenticated by token
+func (c AuthConf) TLSConfig(certs CertsConf) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certs.CertFile, certs.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("can not load server certificate: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if c.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("can not read client CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA file `%s` does not contain PEM certificates", c.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
+
+// AuthInterceptor create interceptor, which authenticates clients by certificate or bearer token.
+// Secrets with tokens are loaded from default cluster
+func (s *deploymentServer) AuthInterceptor(c AuthConf) (grpc.UnaryServerInterceptor, error) {
+	tokens := make(map[string]gitclient.TokenSource)
+	for name, conf := range c.Identities {
+		source, err := conf.tokenSource(s.clusters[s.defaultCluster].clientset)
+		if err != nil {
+			return nil, fmt.Errorf("identity %s: %v", name, err)
+		}
+		if source != nil {
+			tokens[name] = source
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		name, err := c.authenticate(ctx, tokens)
+		if err != nil {
+			log.Printf("%s rejected: %v\n", info.FullMethod, err)
+			if request, ok := req.(*api.Request); ok && info.FullMethod == "/api.Deployment/Deploy" {
+				s.auditDeploy(ctx, request, auditUnauthenticated, err)
+			}
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		log.Printf("%s called by %s\n", info.FullMethod, name)
+		return handler(context.WithValue(ctx, identityKey{}, &identity{name, c.Identities[name]}), req)
+	}, nil
+}
+
+// authenticate find identity of client by verified certificate or by bearer token
+func (c AuthConf) authenticate(ctx context.Context, tokens map[string]gitclient.TokenSource) (string, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			name := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+			if _, ok := c.Identities[name]; ok {
+				return name, nil
+			}
+			return "", fmt.Errorf("unknown identity `%s` of client certificate", name)
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return "", fmt.Errorf("client certificate or bearer token is required")
+	}
+	token := []byte(strings.TrimPrefix(values[0], "Bearer "))
+
+	for name, source := range tokens {
+		expected, err := source.Token()
+		if err != nil {
+			log.Printf("can not load token of identity %s: %v\n", name, err)
+			continue
+		}
+		if expected != "" && subtle.ConstantTimeCompare(token, []byte(expected)) == 1 {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid bearer token")
+}
+
+// clientIdentity return authenticated client, nil is returned when api is not protected
+func clientIdentity(ctx context.Context) *identity {
+	id, _ := ctx.Value(identityKey{}).(*identity)
+	return id
+}
+
+// authorize check that request is in scope of identity of client: path, server mode, cluster and namespaces of kustomizations
+func (s *deploymentServer) authorize(ctx context.Context, request *api.Request) error {
+	id := clientIdentity(ctx)
+	if id == nil {
+		// api is not protected
+		return nil
+	}
+
+	mode := serverModeName(request.Mode)
+	if !allowed(id.conf.Modes, func(m string) bool { return m == mode }) {
+		return status.Errorf(codes.PermissionDenied, "identity %s is not allowed to deploy in mode %s", id.name, mode)
+	}
+
+	source := filepath.Join(s.kustomizations, request.Path)
+	path, err := filepath.Rel(s.kustomizations, source)
+	if err != nil || path == ".." || strings.HasPrefix(path, "../") {
+		return status.Errorf(codes.PermissionDenied, "path `%s` is outside of kustomizations", request.Path)
+	}
+	if path == "." {
+		path = ""
+	}
+	if !id.allowsPath(path) {
+		return status.Errorf(codes.PermissionDenied, "identity %s is not allowed to deploy path `%s`", id.name, path)
+	}
+
+	if request.Cluster != "" && !id.allowsCluster(request.Cluster) {
+		return status.Errorf(codes.PermissionDenied, "identity %s is not allowed to deploy into cluster %s", id.name, request.Cluster)
+	}
+
+	if allowed(id.conf.Namespaces, func(string) bool { return false }) && allowed(id.conf.Clusters, func(string) bool { return false }) {
+		return nil
+	}
+	return filepath.Walk(source, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() || filepath.Base(path) != "kustomization.yaml" {
+			// errors are reported by deployment
+			return nil
+		}
+		filedata, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		kustomization, err := ParseKustomization(filedata)
+		if err != nil {
+			return nil
+		}
+		ns := kustomization.Ns
+		if ns == "" {
+			// objects of kustomization without namespace are created in default namespace
+			ns = metav1.NamespaceDefault
+		}
+		if !id.allowsNamespace(ns) {
+			return status.Errorf(codes.PermissionDenied, "identity %s is not allowed to deploy into namespace %s", id.name, ns)
+		}
+		clusterName := kustomization.Cluster
+		if clusterName == "" {
+			clusterName = s.resolveCluster(request.Cluster)
+		}
+		if !id.allowsCluster(clusterName) {
+			return status.Errorf(codes.PermissionDenied, "identity %s is not allowed to deploy into cluster %s", id.name, clusterName)
+		}
+		return nil
+	})
+}
+
+// allowsPath report whether kustomization path relative to kustomizations dir is in scope of identity,
+// all paths are allowed, when api is not protected
+func (id *identity) allowsPath(path string) bool {
+	return id == nil || allowed(id.conf.Paths, func(prefix string) bool { return hasPathPrefix(path, prefix) })
+}
+
+// allowsNamespace report whether namespace is in scope of identity
+func (id *identity) allowsNamespace(ns string) bool {
+	return id == nil || allowed(id.conf.Namespaces, func(value string) bool { return value == ns })
+}
+
+// allowsCluster report whether cluster is in scope of identity
+func (id *identity) allowsCluster(name string) bool {
+	return id == nil || allowed(id.conf.Clusters, func(value string) bool { return value == name })
+}
+
+// allowed report whether scope contains `*` or value matched by function
+func allowed(scope []string, match func(string) bool) bool {
+	for _, value := range scope {
+		if value == AllowAll || match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix report whether path is equal to prefix or is nested in it
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"demius.md/deployment-operator/utils"
+)
+
+// CheckConfig validate config, load templates and check all kustomizations without access to k8s and providers
+func CheckConfig(config *DeployConfig) error {
+	var errs ConfigErrors
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err.(ConfigErrors)...)
+	}
+	if len(errs) > 0 && !(utils.DirectoryExists(config.DeployTemplates) && utils.DirectoryExists(config.Kustomizations)) {
+		return errs
+	}
+
+	templates, err := LoadTemplates(config.DeployTemplates)
+	if err != nil {
+		return append(errs, fmt.Errorf("templates: %v", err))
+	}
+
+	kubeClusters, defaultCluster := config.KubeClusters()
+	// clusters are not connected, only names are checked
+	clusters := make(map[string]*cluster, len(kubeClusters))
+	for name := range kubeClusters {
+		clusters[name] = &cluster{name: name}
+	}
+
+	s := &deploymentServer{
+		clusters:       clusters,
+		defaultCluster: defaultCluster,
+		templates:      templates,
+		kustomizations: config.Kustomizations,
+		providers:      config.Providers,
+	}
+	prefixLen := len(config.Kustomizations) + 1
+
+	err = filepath.Walk(config.Kustomizations, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walk dir%s: %v", path, err)
+		}
+		if f.IsDir() || filepath.Base(path) != "kustomization.yaml" {
+			return nil
+		}
+		if err := s.checkKustomization(path); err != nil {
+			errs = append(errs, fmt.Errorf("kustomization %s: %v", extrtactArtifactPath(prefixLen, path, f.Name()), err))
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkKustomization check provider, templates and settings of kustomization
+func (s *deploymentServer) checkKustomization(path string) error {
+	filedata, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	kustomization, err := ParseKustomization(filedata)
+	if err != nil {
+		return err
+	}
+
+	repo := &kustomization.Repository
+	if _, ok := s.providers[repo.Provider]; !ok {
+		return fmt.Errorf("unknown git provider `%s`", repo.Provider)
+	}
+	if repo.Group == "" || repo.Project == "" {
+		return fmt.Errorf("`repository.group` and `repository.project` must be declared")
+	}
+	if _, err := s.clusterFor(kustomization, ""); err != nil {
+		return err
+	}
+	if repo.Tag != "" && repo.Version != "" {
+		return fmt.Errorf("only one of `repository.tag` and `repository.version` may be declared")
+	}
+	if err := repo.TagPolicy.Validate(); err != nil {
+		return fmt.Errorf("tag-policy: %v", err)
+	}
+	switch kustomization.OnlyFor {
+	case "", "all", "devel", "prod":
+	default:
+		return fmt.Errorf("only-for must be all, devel or prod, actual: `%s`", kustomization.OnlyFor)
+	}
+	if kustomization.Name == "" || kustomization.Ns == "" {
+		return fmt.Errorf("`name` and `ns` must be declared")
+	}
+
+	switch kustomization.Kind {
+	case "cronjob":
+		if s.templates[CronJobKind][""] == nil {
+			return fmt.Errorf("not found template of cronjob")
+		}
+	case "deployment":
+		if s.deploymentTemplate(kustomization) == nil {
+			return fmt.Errorf("not found template of deployment for tier `%s`", kustomization.Tier)
+		}
+		if kustomization.Service != nil && s.templates[ServiceKind][kustomization.Service.ServiceTemplate] == nil {
+			return fmt.Errorf("not found template of service `%s`", kustomization.Service.ServiceTemplate)
+		}
+	default:
+		if s.resourceTemplate(kustomization) == nil {
+			return fmt.Errorf("unknown kind of kustomization `%s`", kustomization.Kind)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// DefaultCluster is name of cluster declared by `kube`, when `clusters` are not declared
+const DefaultCluster = "default"
+
+// cluster contains clients of k8s cluster, where kustomizations are deployed
+type cluster struct {
+	name      string
+	clientset *kubernetes.Clientset
+	dynamic   dynamic.Interface
+	mapper    *restmapper.DeferredDiscoveryRESTMapper
+
+	cronjobs     cronjobClient
+	cronjobsLock sync.Mutex
+
+	namespaces sync.Map // uid and settings revision of namespaces bootstraped by ensureNamespace
+	kinds      sync.Map // mappings of generic kinds resolved by RESTMapper, managed objects of these kinds are pruned
+}
+
+// connectCluster create clients of k8s cluster, k8s api is not requested until first call
+func connectCluster(name string, conf KubeConf) (*cluster, error) {
+	config, err := conf.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	return &cluster{name: name, clientset: clientset, dynamic: dynamicClient, mapper: mapper}, nil
+}
+
+// resolveCluster return name of cluster, default cluster is returned for empty name
+func (s *deploymentServer) resolveCluster(name string) string {
+	if name == "" {
+		return s.defaultCluster
+	}
+	return name
+}
+
+// clusterFor find cluster of kustomization. Cluster of request is used for kustomizations without `cluster`,
+// kustomization bound to other cluster is not deployed
+func (s *deploymentServer) clusterFor(kustomization *Kustomization, name string) (*cluster, error) {
+	if kustomization.Cluster != "" {
+		if name != "" && name != kustomization.Cluster {
+			return nil, fmt.Errorf("kustomization is bound to cluster `%s`, requested: `%s`", kustomization.Cluster, name)
+		}
+		name = kustomization.Cluster
+	}
+	if name == "" {
+		name = s.defaultCluster
+	}
+	c, ok := s.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster `%s`", name)
+	}
+	return c, nil
+}
+
+// clusterNames return sorted names of declared clusters
+func (s *deploymentServer) clusterNames() []string {
+	names := make([]string, 0, len(s.clusters))
+	for name := range s.clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,19 +1,47 @@
 package service
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"demius.md/deployment-operator/gitclient"
+	"demius.md/deployment-operator/utils"
+)
+
+const (
+	// DefaultListenAddress is address of grpc server and webhooks listener, when it is not declared
+	DefaultListenAddress = "0.0.0.0"
+	// DefaultMetricsAddress is address of metrics listener, when it is not declared
+	DefaultMetricsAddress = "127.0.0.1"
 )
 
 // DeployConfig contains all info about deployment in k8s
 type DeployConfig struct {
 	Certs           CertsConf                 `yaml:"certs"`
+	ListenAddress   string                    `yaml:"listen-address"` // default 0.0.0.0
 	ServerPort      int                       `yaml:"server-port"`
 	DeployTemplates string                    `yaml:"templates"`
 	Kustomizations  string                    `yaml:"kustomizations"`
+	OwnerID         string                    `yaml:"owner-id"` // required identifier of operator instance stamped on objects, only own objects are pruned
 	Providers       map[string]ProviderConfig `yaml:"providers"`
+	Webhook         WebhookConf               `yaml:"webhook"`
+	Metrics         MetricsConf               `yaml:"metrics"` // admin listener of metrics, separate from public webhooks listener
+	Poller          PollerConf                `yaml:"poller"`
+	Kube            KubeConf                  `yaml:"kube"`
+	Clusters        map[string]KubeConf       `yaml:"clusters"`        // named clusters, replace cluster declared by `kube`
+	DefaultCluster  string                    `yaml:"default-cluster"` // required, when several clusters are declared
+	Namespaces      NamespaceConf             `yaml:"namespaces"`
+	Auth            AuthConf                  `yaml:"auth"`    // authentication and scopes of grpc clients
+	Audit           AuditConf                 `yaml:"audit"`   // audit log of deployments
+	History         HistoryConf               `yaml:"history"` // bounded history of releases applied to services
 }
 
 // CertsConf contains location of key/cert files
@@ -22,24 +50,212 @@ type CertsConf struct {
 	CertFile string `yaml:"cert-file"`
 }
 
+// MetricsConf contains settings of http listener of metrics
+type MetricsConf struct {
+	Port          int    `yaml:"port"`           // metrics are not served, when port is not declared
+	ListenAddress string `yaml:"listen-address"` // default 127.0.0.1
+}
+
 // ProviderConfig contains info about git provider
 type ProviderConfig struct {
-	URL    string `yaml:"url"`          // base url of git provider
-	Type   string `yaml:"api-type"`     // may be gitlab or github
-	Secret string `yaml:"secret-token"` // api access secret token
+	URL           string               `yaml:"url"`                  // base url of provider, `https://<provider>` by default, for github only GitHub Enterprise server
+	Type          string               `yaml:"api-type"`             // may be gitlab, github, gitea, forgejo or registry
+	Secret        string               `yaml:"secret-token"`         // api access secret token
+	SecretFile    string               `yaml:"secret-token-file"`    // file with secret token, re-read for every request
+	SecretEnv     string               `yaml:"secret-token-env"`     // environment variable with secret token
+	SecretRef     *SecretKeyRef        `yaml:"secret-token-ref"`     // key of k8s secret with secret token
+	TagPolicy     *gitclient.TagPolicy `yaml:"tag-policy"`           // default rules of release tag selection
+	CacheTTL      time.Duration        `yaml:"cache-ttl"`            // time of life of cached releases, default 1m, negative disables cache
+	WebhookSecret string               `yaml:"webhook-secret"`       // secret of webhooks: HMAC key for github and gitea, token for gitlab
+	TLS           gitclient.TLSConfig  `yaml:"tls"`                  // CA bundle, client certificate, verification of server certificate
+	Registry      *RegistryCredentials `yaml:"registry-credentials"` // credentials of image registry, propagated into namespaces as image pull secret
+	AuthHosts     []string             `yaml:"auth-hosts"`           // hosts of token services of registry provider, which may receive credentials
+}
+
+// gitclientConfig create settings of connection to provider
+func (c ProviderConfig) gitclientConfig(provider string, clientset kubernetes.Interface) (gitclient.Config, error) {
+	transport, err := gitclient.NewTransport(c.TLS)
+	if err != nil {
+		return gitclient.Config{}, err
+	}
+	if c.TLS.Insecure {
+		log.Printf("verification of certificates of provider %s is disabled\n", provider)
+	}
+	token, err := c.tokenSource(clientset)
+	if err != nil {
+		return gitclient.Config{}, err
+	}
+	return gitclient.Config{Provider: provider, URL: c.URL, Token: token, Transport: transport, AuthHosts: c.AuthHosts}, nil
+}
+
+// ConfigErrors contains all errors found by validation of config
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid config:\n  " + strings.Join(messages, "\n  ")
 }
 
 // LoadDeployConfig load config of deployment
-func LoadDeployConfig(path string) *DeployConfig {
+func LoadDeployConfig(path string) (*DeployConfig, error) {
 	file, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("%s get err #%v", path, err)
+		return nil, fmt.Errorf("can not read config %s: %v", path, err)
 	}
-	var config = DeployConfig{}
+	var config = DeployConfig{ListenAddress: DefaultListenAddress, Metrics: MetricsConf{ListenAddress: DefaultMetricsAddress}}
 
 	if err = yaml.Unmarshal(file, &config); err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+		return nil, fmt.Errorf("can not parse config %s: %v", path, err)
+	}
+
+	return &config, nil
+}
+
+// Validate check config and return all found errors as ConfigErrors
+func (c *DeployConfig) Validate() error {
+	var errs ConfigErrors
+	addError := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.ServerPort <= 0 || c.ServerPort > 65535 {
+		addError("server-port must be in range 1-65535, actual: %d", c.ServerPort)
+	}
+	if c.Certs.CertFile == "" || c.Certs.KeyFile == "" {
+		addError("certs: key-file and cert-file must be declared")
+	} else {
+		if !utils.FileExists(c.Certs.CertFile) {
+			addError("certs: cert-file %s does not exist", c.Certs.CertFile)
+		}
+		if !utils.FileExists(c.Certs.KeyFile) {
+			addError("certs: key-file %s does not exist", c.Certs.KeyFile)
+		}
+	}
+	if !utils.DirectoryExists(c.DeployTemplates) {
+		addError("templates: directory `%s` does not exist", c.DeployTemplates)
+	}
+	if !utils.DirectoryExists(c.Kustomizations) {
+		addError("kustomizations: directory `%s` does not exist", c.Kustomizations)
+	}
+	if c.OwnerID == "" {
+		addError("owner-id must be declared")
+	}
+	for _, msg := range validation.IsValidLabelValue(c.OwnerID) {
+		addError("owner-id: %s", msg)
 	}
 
-	return &config
+	for provider, providerConf := range c.Providers {
+		switch providerConf.Type {
+		case "gitlab", "github", "gitea", "forgejo", "registry":
+		default:
+			addError("provider %s: unknown api-type `%s`", provider, providerConf.Type)
+		}
+		if _, err := providerConf.tokenSource(nil); err != nil {
+			addError("provider %s: %v", provider, err)
+		}
+		if _, err := gitclient.NewTransport(providerConf.TLS); err != nil {
+			addError("provider %s: tls: %v", provider, err)
+		}
+		if _, err := providerConf.pullSecret(provider, nil); err != nil {
+			addError("provider %s: %v", provider, err)
+		}
+		if err := providerConf.TagPolicy.Validate(); err != nil {
+			addError("provider %s: tag-policy: %v", provider, err)
+		}
+	}
+
+	if len(c.Clusters) > 0 && (c.Kube.Kubeconfig != "" || c.Kube.Context != "") {
+		addError("only one of `kube` and `clusters` may be declared")
+	}
+	clusters, defaultCluster := c.KubeClusters()
+	if _, ok := clusters[defaultCluster]; !ok {
+		addError("default-cluster `%s` is not declared in clusters", defaultCluster)
+	}
+	for name, kube := range clusters {
+		if kube.Kubeconfig != "" && !utils.FileExists(kube.Kubeconfig) {
+			addError("cluster %s: kubeconfig %s does not exist", name, kube.Kubeconfig)
+		}
+	}
+
+	if c.Audit.File != "" && !utils.DirectoryExists(filepath.Dir(c.Audit.File)) {
+		addError("audit: directory of file %s does not exist", c.Audit.File)
+	}
+	if err := c.Auth.Validate(); err != nil {
+		addError("auth: %v", err)
+	}
+	if err := c.Namespaces.Validate(); err != nil {
+		addError("namespaces: %v", err)
+	}
+
+	if c.Webhook.Port < 0 || c.Webhook.Port > 65535 {
+		addError("webhook: port must be in range 1-65535, actual: %d", c.Webhook.Port)
+	}
+	if c.Webhook.Port != 0 && c.Webhook.Port == c.ServerPort {
+		addError("webhook: port must differ from server-port")
+	}
+	if c.Metrics.Port < 0 || c.Metrics.Port > 65535 {
+		addError("metrics: port must be in range 1-65535, actual: %d", c.Metrics.Port)
+	}
+	if c.Metrics.Port != 0 && (c.Metrics.Port == c.ServerPort || c.Metrics.Port == c.Webhook.Port) {
+		addError("metrics: port must differ from server-port and webhook port")
+	}
+	if !validMode(c.Webhook.Mode, true) {
+		addError("webhook: mode must be devel or prod, actual: `%s`", c.Webhook.Mode)
+	}
+	if _, ok := clusters[c.Webhook.Cluster]; c.Webhook.Cluster != "" && !ok {
+		addError("webhook: cluster `%s` is not declared in clusters", c.Webhook.Cluster)
+	}
+	for mode := range c.Poller.Intervals {
+		if !validMode(mode, false) {
+			addError("poller: mode must be devel or prod, actual: `%s`", mode)
+		}
+	}
+	polled := make(map[string]string)
+	for _, mode := range []string{"devel", "prod"} {
+		if c.Poller.Intervals[mode] <= 0 {
+			continue
+		}
+		name := c.Poller.Clusters[mode]
+		if name == "" {
+			name = defaultCluster
+		}
+		if other, ok := polled[name]; ok {
+			addError("poller: modes %s and %s must not poll the same cluster `%s`", other, mode, name)
+		}
+		polled[name] = mode
+	}
+	for mode, name := range c.Poller.Clusters {
+		if !validMode(mode, false) {
+			addError("poller: mode of cluster must be devel or prod, actual: `%s`", mode)
+		}
+		if _, ok := clusters[name]; !ok {
+			addError("poller: cluster `%s` of mode %s is not declared in clusters", name, mode)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// KubeClusters return declared clusters and name of default one. Cluster `default` declared by `kube`
+// is returned, when `clusters` are not declared
+func (c *DeployConfig) KubeClusters() (map[string]KubeConf, string) {
+	if len(c.Clusters) == 0 {
+		return map[string]KubeConf{DefaultCluster: c.Kube}, DefaultCluster
+	}
+	if c.DefaultCluster == "" && len(c.Clusters) == 1 {
+		for name := range c.Clusters {
+			return c.Clusters, name
+		}
+	}
+	return c.Clusters, c.DefaultCluster
+}
+
+func validMode(mode string, allowEmpty bool) bool {
+	return mode == "devel" || mode == "prod" || (allowEmpty && mode == "")
 }
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"demius.md/deployment-operator/gitclient"
+)
+
+func TestDeployConfigValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	templates, kustomizations := filepath.Join(dir, "templates"), filepath.Join(dir, "kustomizations")
+	certs := CertsConf{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	for _, path := range []string{templates, kustomizations} {
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{certs.CertFile, certs.KeyFile} {
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config DeployConfig
+		errors []string
+	}{
+		{
+			name:   "valid config",
+			config: DeployConfig{Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations},
+		},
+		{
+			name:   "all errors are reported",
+			config: DeployConfig{OwnerID: "test", DeployTemplates: templates, Kustomizations: "/not/found"},
+			errors: []string{"server-port must be in range", "key-file and cert-file must be declared", "kustomizations: directory"},
+		},
+		{
+			name: "missing cert file",
+			config: DeployConfig{
+				Certs:      CertsConf{CertFile: certs.CertFile + ".missing", KeyFile: certs.KeyFile},
+				ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+			},
+			errors: []string{"cert-file"},
+		},
+		{
+			name:   "invalid owner id",
+			config: DeployConfig{Certs: certs, ServerPort: 7000, OwnerID: "not valid/label", DeployTemplates: templates, Kustomizations: kustomizations},
+			errors: []string{"owner-id:"},
+		},
+		{
+			name:   "owner id is required",
+			config: DeployConfig{Certs: certs, ServerPort: 7000, DeployTemplates: templates, Kustomizations: kustomizations},
+			errors: []string{"owner-id must be declared"},
+		},
+		{
+			name: "invalid provider",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Providers: map[string]ProviderConfig{"git.io": {Type: "svn", Secret: "a", SecretEnv: "B"}},
+			},
+			errors: []string{"provider git.io: unknown api-type `svn`", "provider git.io: "},
+		},
+		{
+			name: "invalid tag policy",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Providers: map[string]ProviderConfig{"gitlab.com": {
+					Type:      "gitlab",
+					Secret:    "token",
+					TagPolicy: &gitclient.TagPolicy{Modes: map[string]gitclient.TagRule{"prod": {Regex: "("}}},
+				}},
+			},
+			errors: []string{"provider gitlab.com: tag-policy:"},
+		},
+		{
+			name: "kube and clusters",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Kube:     KubeConf{Context: "dev"},
+				Clusters: map[string]KubeConf{"east": {}},
+			},
+			errors: []string{"only one of `kube` and `clusters` may be declared"},
+		},
+		{
+			name: "default cluster is required for several clusters",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Clusters: map[string]KubeConf{"east": {}, "west": {}},
+			},
+			errors: []string{"default-cluster `` is not declared"},
+		},
+		{
+			name: "single cluster is default",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Clusters: map[string]KubeConf{"east": {}},
+			},
+		},
+		{
+			name: "invalid quota",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Namespaces: NamespaceConf{ResourceQuota: map[string]string{"requests.cpu": "four"}},
+			},
+			errors: []string{"namespaces: resource-quota:"},
+		},
+		{
+			name: "invalid identity",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Auth: AuthConf{Identities: map[string]Identity{"ci": {Token: "t", Modes: []string{"test"}}}},
+			},
+			errors: []string{"auth: identity ci: mode must be devel, prod or *"},
+		},
+		{
+			name: "webhook of single cluster",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Clusters: map[string]KubeConf{"east": {}},
+				Webhook:  WebhookConf{Port: 7001, Cluster: "east"},
+			},
+		},
+		{
+			name: "modes and clusters of webhook and poller",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Webhook: WebhookConf{Mode: "test", Cluster: "east"},
+				Poller: PollerConf{
+					Intervals: map[string]time.Duration{"staging": time.Minute},
+					Clusters:  map[string]string{"prod": "west"},
+				},
+			},
+			errors: []string{
+				"webhook: mode must be devel or prod",
+				"webhook: cluster `east` is not declared",
+				"poller: mode must be devel or prod, actual: `staging`",
+				"poller: cluster `west` of mode prod is not declared",
+			},
+		},
+		{
+			name: "poller modes of the same cluster",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Poller: PollerConf{Intervals: map[string]time.Duration{"devel": time.Minute, "prod": time.Minute}},
+			},
+			errors: []string{"poller: modes devel and prod must not poll the same cluster `default`"},
+		},
+		{
+			name: "poller modes of different clusters",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Clusters:       map[string]KubeConf{"east": {}, "west": {}},
+				DefaultCluster: "east",
+				Poller: PollerConf{
+					Intervals: map[string]time.Duration{"devel": time.Minute, "prod": time.Minute},
+					Clusters:  map[string]string{"prod": "west"},
+				},
+			},
+		},
+		{
+			name: "ports of listeners",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Webhook: WebhookConf{Port: 7000},
+				Metrics: MetricsConf{Port: 70000},
+			},
+			errors: []string{"webhook: port must differ from server-port", "metrics: port must be in range"},
+		},
+		{
+			name: "metrics port differs from webhook port",
+			config: DeployConfig{
+				Certs: certs, ServerPort: 7000, OwnerID: "test", DeployTemplates: templates, Kustomizations: kustomizations,
+				Webhook: WebhookConf{Port: 7001},
+				Metrics: MetricsConf{Port: 7001},
+			},
+			errors: []string{"metrics: port must differ"},
+		},
+	}
+	for _, test := range tests {
+		err := test.config.Validate()
+		if len(test.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		errs, ok := err.(ConfigErrors)
+		if !ok {
+			t.Errorf("%s: Validate = %v, expected ConfigErrors", test.name, err)
+			continue
+		}
+		if len(errs) != len(test.errors) {
+			t.Errorf("%s: %d errors, expected %d: %v", test.name, len(errs), len(test.errors), err)
+		}
+		for _, expected := range test.errors {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: error `%s` is not reported: %v", test.name, expected, err)
+			}
+		}
+	}
+}
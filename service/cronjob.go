@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	apibatch "k8s.io/api/batch/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// CronJobV1 is api version of cronjobs served since k8s 1.21
+	CronJobV1 = "batch/v1"
+	// CronJobV1beta1 is api version of cronjobs served by old clusters, removed in k8s 1.25
+	CronJobV1beta1 = "batch/v1beta1"
+)
+
+var cronjobsV1Resource = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}
+
+// cronjobClient is abstraction over cronjob api versions served by cluster.
+// Both versions are read as batch/v1beta1 types, fields served only by batch/v1 (e.g. `timeZone`)
+// are kept in objects rendered by cronjobObject
+type cronjobClient interface {
+	APIVersion() string
+	Get(ctx context.Context, ns, name string) (*apibatch.CronJob, error)
+	List(ctx context.Context, ns string, opts metav1.ListOptions) ([]apibatch.CronJob, error)
+	Delete(ctx context.Context, ns, name string) error
+}
+
+// cronjobAPI find cronjob api version served by cluster via discovery
+func (c *cluster) cronjobAPI() cronjobClient {
+	c.cronjobsLock.Lock()
+	defer c.cronjobsLock.Unlock()
+
+	if c.cronjobs != nil {
+		return c.cronjobs
+	}
+
+	resources, err := c.clientset.Discovery().ServerResourcesForGroupVersion(CronJobV1)
+	if err != nil {
+		// do not cache result, discovery will be repeated on next call
+		log.Printf("can not discover cronjob api version, use %s: %v\n", CronJobV1beta1, err)
+		return &cronjobsV1beta1{c.clientset}
+	}
+
+	c.cronjobs = &cronjobsV1beta1{c.clientset}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "cronjobs" {
+			c.cronjobs = &cronjobsV1{c.dynamic}
+			break
+		}
+	}
+	log.Printf("cronjobs are served with api version %s\n", c.cronjobs.APIVersion())
+	return c.cronjobs
+}
+
+type cronjobsV1beta1 struct {
+	clientset *kubernetes.Clientset
+}
+
+func (c *cronjobsV1beta1) APIVersion() string {
+	return CronJobV1beta1
+}
+
+func (c *cronjobsV1beta1) Get(ctx context.Context, ns, name string) (*apibatch.CronJob, error) {
+	return c.clientset.BatchV1beta1().CronJobs(ns).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *cronjobsV1beta1) List(ctx context.Context, ns string, opts metav1.ListOptions) ([]apibatch.CronJob, error) {
+	jobs, err := c.clientset.BatchV1beta1().CronJobs(ns).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+func (c *cronjobsV1beta1) Delete(ctx context.Context, ns, name string) error {
+	return c.clientset.BatchV1beta1().CronJobs(ns).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// cronjobsV1 use dynamic client, because typed client of batch/v1 cronjobs is not available in used k8s api
+type cronjobsV1 struct {
+	dynamic dynamic.Interface
+}
+
+func (c *cronjobsV1) APIVersion() string {
+	return CronJobV1
+}
+
+func (c *cronjobsV1) Get(ctx context.Context, ns, name string) (*apibatch.CronJob, error) {
+	obj, err := c.dynamic.Resource(cronjobsV1Resource).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return cronjobFromUnstructured(obj)
+}
+
+func (c *cronjobsV1) List(ctx context.Context, ns string, opts metav1.ListOptions) ([]apibatch.CronJob, error) {
+	list, err := c.dynamic.Resource(cronjobsV1Resource).Namespace(ns).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]apibatch.CronJob, len(list.Items))
+	for i := range list.Items {
+		job, err := cronjobFromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		jobs[i] = *job
+	}
+	return jobs, nil
+}
+
+func (c *cronjobsV1) Delete(ctx context.Context, ns, name string) error {
+	return c.dynamic.Resource(cronjobsV1Resource).Namespace(ns).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func cronjobFromUnstructured(obj *unstructured.Unstructured) (*apibatch.CronJob, error) {
+	job := &apibatch.CronJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job); err != nil {
+		return nil, fmt.Errorf("can not convert %s cronjob `%s`: %v", CronJobV1, obj.GetName(), err)
+	}
+	return job, nil
+}
+
+// cronjobObject decode cronjob from manifest for api version served by cluster. Typed cronjob of batch/v1beta1
+// is completed by fields of manifest, which are served only by batch/v1, e.g. `spec.timeZone`
+func (c *cluster) cronjobObject(manifest []byte, env []EnvVar, initVariables []EnvVar, owner ownership) (*unstructured.Unstructured, error) {
+	j, err := decodeCronjob(manifest, env, initVariables, owner)
+	if err != nil {
+		return nil, err
+	}
+	apiVersion := c.cronjobAPI().APIVersion()
+	obj, err := typedObject(j, apiVersion, "CronJob")
+	if err != nil || apiVersion != CronJobV1 {
+		return obj, err
+	}
+
+	raw := map[string]interface{}{}
+	if err := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000).Decode(&raw); err != nil {
+		return nil, err
+	}
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	rawSpec, rawOk := raw["spec"].(map[string]interface{})
+	if ok && rawOk {
+		addMissingFields(spec, rawSpec)
+	}
+	return obj, nil
+}
+
+// addMissingFields add fields of src, which do not exist in dst. Lists are not merged
+func addMissingFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		current, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		currentFields, currentOk := current.(map[string]interface{})
+		fields, ok := value.(map[string]interface{})
+		if currentOk && ok {
+			addMissingFields(currentFields, fields)
+		}
+	}
+}
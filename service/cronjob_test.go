@@ -0,0 +1,46 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddMissingFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      map[string]interface{}
+		src      map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "field of batch/v1 is added",
+			dst:      map[string]interface{}{"schedule": "0 * * * *"},
+			src:      map[string]interface{}{"schedule": "0 * * * *", "timeZone": "Europe/Chisinau"},
+			expected: map[string]interface{}{"schedule": "0 * * * *", "timeZone": "Europe/Chisinau"},
+		},
+		{
+			name:     "typed fields are kept",
+			dst:      map[string]interface{}{"suspend": true},
+			src:      map[string]interface{}{"suspend": "true"},
+			expected: map[string]interface{}{"suspend": true},
+		},
+		{
+			name:     "nested fields are added",
+			dst:      map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{}}},
+			src:      map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"podReplacementPolicy": "Failed"}}},
+			expected: map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"podReplacementPolicy": "Failed"}}},
+		},
+		{
+			name:     "lists are not merged",
+			dst:      map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app", "env": []interface{}{"A"}}}},
+			src:      map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app"}}},
+			expected: map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "app", "env": []interface{}{"A"}}}},
+		},
+	}
+	for _, test := range tests {
+		addMissingFields(test.dst, test.src)
+		if !reflect.DeepEqual(test.dst, test.expected) {
+			t.Errorf("%s: addMissingFields = %v, expected %v", test.name, test.dst, test.expected)
+		}
+	}
+}
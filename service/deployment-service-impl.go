@@ -2,15 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"text/template"
 	"time"
 
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	"google.golang.org/grpc"
 
 	"demius.md/deployment-operator/api"
 	"demius.md/deployment-operator/gitclient"
@@ -20,33 +23,84 @@ import (
 const MaxServicesCount = 32
 
 type deploymentServer struct {
-	clientset *kubernetes.Clientset
+	clusters       map[string]*cluster
+	defaultCluster string
 
 	templates      Templates
 	kustomizations string
+	instance       string
 	providers      map[string]ProviderConfig
+	namespaces     NamespaceConf
 
-	gitclients map[string]gitclient.GitClient
+	gitclients  map[string]gitclient.GitClient
+	pullSecrets map[string]*pullSecret
+	audit       *auditLog
+	history     *releaseHistory
+	deploying   pathLocks
 }
 
-// NewServer create new grpc server
-func NewServer(deployTemplates, kustomizations string, providers map[string]ProviderConfig) api.DeploymentServer {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		panic(err.Error())
+// pathLocks serialize deployments of the same kustomization by grpc api, webhooks and poller
+type pathLocks struct {
+	lock  sync.Mutex
+	paths map[string]*sync.Mutex
+}
+
+// acquire lock kustomization path and return function, which unlocks it
+func (l *pathLocks) acquire(path string) func() {
+	l.lock.Lock()
+	if l.paths == nil {
+		l.paths = make(map[string]*sync.Mutex)
+	}
+	pathLock, ok := l.paths[path]
+	if !ok {
+		pathLock = &sync.Mutex{}
+		l.paths[path] = pathLock
+	}
+	l.lock.Unlock()
+
+	pathLock.Lock()
+	return pathLock.Unlock
+}
+
+// Server is grpc deployment server, which also deploys new releases reported by webhooks or found by poller
+type Server interface {
+	api.DeploymentServer
+	WebhookHandler(conf WebhookConf) http.Handler
+	StartPoller(ctx context.Context, conf PollerConf)
+	AuthInterceptor(conf AuthConf) (grpc.UnaryServerInterceptor, error)
+}
+
+// NewServer create new grpc server, kustomizations are deployed into default cluster unless other one is selected
+func NewServer(config *DeployConfig) Server {
+	providers := config.Providers
+	kubeClusters, defaultCluster := config.KubeClusters()
+
+	clusters := make(map[string]*cluster, len(kubeClusters))
+	for name, kube := range kubeClusters {
+		c, err := connectCluster(name, kube)
+		if err != nil {
+			panic(fmt.Sprintf("cluster %s: %v", name, err))
+		}
+		clusters[name] = c
+	}
+	if _, ok := clusters[defaultCluster]; !ok {
+		panic(fmt.Sprintf("default cluster `%s` is not declared", defaultCluster))
 	}
-	// creates the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	// secrets of providers are loaded from default cluster
+	clientset := clusters[defaultCluster].clientset
+
+	templates, err := LoadTemplates(config.DeployTemplates)
 	if err != nil {
 		panic(err.Error())
 	}
 
-	templates, err := LoadTemplates(deployTemplates)
+	audit, err := newAuditLog(config.Audit)
 	if err != nil {
 		panic(err.Error())
 	}
 
 	gitclients := make(map[string]gitclient.GitClient)
+	pullSecrets := make(map[string]*pullSecret)
 
 	for provider, providerConf := range providers {
 		var gitcli gitclient.GitClient
@@ -54,25 +108,56 @@ func NewServer(deployTemplates, kustomizations string, providers map[string]Prov
 		if val, ok := gitclients[provider]; ok {
 			gitcli = val
 		} else {
+			conf, err := providerConf.gitclientConfig(provider, clientset)
+			if err != nil {
+				panic(fmt.Sprintf("provider %s: %v", provider, err))
+			}
+
 			if providerConf.Type == "gitlab" {
 				println("   connect to gitlab provider " + provider)
-				gitcli = gitclient.ConnectGitlab(provider, providerConf.Secret)
+				gitcli = gitclient.ConnectGitlab(conf)
 			} else if providerConf.Type == "github" {
 				println("   connect to github provider " + provider)
-				gitcli = gitclient.ConnectGithub(provider, providerConf.Secret)
+				gitcli = gitclient.ConnectGithub(conf)
+			} else if providerConf.Type == "gitea" || providerConf.Type == "forgejo" {
+				println("   connect to gitea provider " + provider)
+				gitcli = gitclient.ConnectGitea(conf)
+			} else if providerConf.Type == "registry" {
+				println("   connect to registry provider " + provider)
+				gitcli = gitclient.ConnectRegistry(conf)
 			} else {
 				println("Unknwn provider type: " + providerConf.Type)
 				continue
 			}
 
 			// TODO: may be logic error
-			gitclients[provider] = gitcli
+			gitclients[provider] = gitclient.NewCachedClient(provider, gitcli, providerConf.CacheTTL)
+
+			secret, err := providerConf.pullSecret(provider, clientset)
+			if err != nil {
+				panic(fmt.Sprintf("provider %s: %v", provider, err))
+			}
+			if secret != nil {
+				pullSecrets[provider] = secret
+			}
 		}
 
 	}
 
 	println("deployment-server-impl created")
-	s := &deploymentServer{clientset, templates, kustomizations, providers, gitclients}
+	s := &deploymentServer{
+		clusters:       clusters,
+		defaultCluster: defaultCluster,
+		templates:      templates,
+		kustomizations: config.Kustomizations,
+		instance:       config.OwnerID,
+		providers:      providers,
+		namespaces:     config.Namespaces,
+		gitclients:     gitclients,
+		pullSecrets:    pullSecrets,
+		audit:          audit,
+		history:        newReleaseHistory(config.History, clientset),
+	}
 	return s
 }
 
@@ -88,7 +173,31 @@ func (s *deploymentServer) Deploy(ctx context.Context, request *api.Request) (*a
 
 	log.Printf("request %s %v\n", source, request.Recreate)
 
-	return s.walkApplications(ctx, prefixLen, source, request.Recreate, request.Mode)
+	if err := s.authorize(ctx, request); err != nil {
+		s.auditDeploy(ctx, request, auditDenied, err)
+		return nil, err
+	}
+	s.auditDeploy(ctx, request, auditAccepted, nil)
+
+	if _, ok := s.clusters[request.Cluster]; request.Cluster != "" && !ok {
+		return respError("unknown cluster `" + request.Cluster + "`"), nil
+	}
+
+	response, err := s.walkApplications(ctx, prefixLen, source, request.Recreate, request.Mode, request.Cluster)
+	if err != nil || !request.Prune {
+		return response, err
+	}
+
+	removed, err := s.pruneApplications(ctx, request.Path, request.Cluster)
+	if err != nil {
+		return respError("can not prune removed kustomizations: " + err.Error()), nil
+	}
+	for _, serviceInfo := range removed {
+		s.auditService(ctx, serviceInfo, request.Mode, nil)
+	}
+	servicesResponse := response.GetServicesResponse()
+	servicesResponse.Services = append(servicesResponse.Services, removed...)
+	return response, nil
 }
 
 func respError(errorDesc string) *api.Response {
@@ -99,7 +208,7 @@ func respError(errorDesc string) *api.Response {
 	}
 }
 
-func (s *deploymentServer) walkApplications(ctx context.Context, prefixLen int, source string, recreate bool, serverMode api.ServerMode) (*api.Response, error) {
+func (s *deploymentServer) walkApplications(ctx context.Context, prefixLen int, source string, recreate bool, serverMode api.ServerMode, clusterName string) (*api.Response, error) {
 	services := make([]*api.ServiceInfo, MaxServicesCount)
 	idx := 0
 	err := filepath.Walk(source, func(path string, f os.FileInfo, err error) error {
@@ -110,7 +219,7 @@ func (s *deploymentServer) walkApplications(ctx context.Context, prefixLen int,
 			return nil
 		}
 
-		services[idx] = s.handleKustomization(ctx, prefixLen, path, recreate, serverMode)
+		services[idx] = s.handleKustomization(ctx, prefixLen, path, recreate, serverMode, clusterName)
 		idx++
 
 		if idx >= MaxServicesCount {
@@ -127,7 +236,18 @@ func (s *deploymentServer) walkApplications(ctx context.Context, prefixLen int,
 	return response, err
 }
 
-func (s *deploymentServer) handleKustomization(ctx context.Context, prefixLen int, path string, recreate bool, serverMode api.ServerMode) *api.ServiceInfo {
+// handleKustomization deploy kustomization and record result into audit log and history of releases
+func (s *deploymentServer) handleKustomization(ctx context.Context, prefixLen int, path string, recreate bool, serverMode api.ServerMode, clusterName string) *api.ServiceInfo {
+	defer s.deploying.acquire(filepath.Clean(path))()
+
+	state := &serviceAudit{}
+	serviceInfo := s.deployKustomization(ctx, prefixLen, path, recreate, serverMode, clusterName, state)
+	s.auditService(ctx, serviceInfo, serverMode, state)
+	s.recordHistory(ctx, serviceInfo, state)
+	return serviceInfo
+}
+
+func (s *deploymentServer) deployKustomization(ctx context.Context, prefixLen int, path string, recreate bool, serverMode api.ServerMode, clusterName string, state *serviceAudit) *api.ServiceInfo {
 	filename := filepath.Base(path)
 
 	serviceInfo := &api.ServiceInfo{
@@ -152,63 +272,174 @@ func (s *deploymentServer) handleKustomization(ctx context.Context, prefixLen in
 		Package: kustomization.Name,
 		Kind:    kustomization.Kind,
 	}
+	state.namespace = kustomization.Ns
 
-	gitclient := s.gitclients[kustomization.Repository.Provider]
-
-	serviceInfo.Provider = gitclient.ProviderName()
-
-	var srvMode string
-
-	if serverMode == api.ServerMode_Development {
-		srvMode = "devel"
-	} else {
-		srvMode = "prod"
+	gitcli, err := s.gitclientFor(kustomization)
+	if err != nil {
+		return serviceInfoWithError(serviceInfo, err.Error())
 	}
 
-	disabled := !(kustomization.OnlyFor == "" || kustomization.OnlyFor == "all" || kustomization.OnlyFor == srvMode)
+	serviceInfo.Provider = gitcli.ProviderName()
 
-	releaseInfo, err := gitclient.LoadImageTag(kustomization.Repository.Group, kustomization.Repository.Project, srvMode)
+	cluster, err := s.clusterFor(kustomization, clusterName)
 	if err != nil {
-		return serviceInfoWithError(serviceInfo, "can not load image tag from git: "+err.Error())
+		return serviceInfoWithError(serviceInfo, err.Error())
 	}
 
-	if releaseInfo == nil {
-		return serviceInfoWithError(serviceInfo, "not found group or project in git")
-	}
+	serviceInfo.Cluster = cluster.name
 
-	if len(releaseInfo.ImageTag) == 0 {
-		return serviceInfoWithError(serviceInfo, "not found image tag in git")
+	srvMode := serverModeName(serverMode)
+
+	disabled := !(kustomization.OnlyFor == "" || kustomization.OnlyFor == "all" || kustomization.OnlyFor == srvMode)
+
+	releaseInfo, err := s.loadRelease(gitcli, kustomization, srvMode)
+	if err != nil {
+		return serviceInfoWithError(serviceInfo, err.Error())
 	}
 
 	serviceInfo.Release = releaseInfo
 
 	log.Printf("srv: %s/%s - %s:%s\n", kustomization.Repository.Group, kustomization.Name, kustomization.Kind, releaseInfo.ImageTag)
 
-	initVariables := []EnvVar{{Name: "APP_SERVER_MODE", Value: srvMode}}
+	pullSecrets := s.pullSecretsFor(kustomization)
+	if !disabled {
+		serviceInfo.Namespace, err = cluster.ensureNamespace(ctx, s.namespaces, kustomization.Ns, s.clusters[s.defaultCluster].clientset)
+		if err != nil {
+			return serviceInfoWithError(serviceInfo, err.Error())
+		}
+		if kustomization.Kind == "cronjob" || kustomization.Kind == "deployment" {
+			if err := cluster.ensurePullSecrets(ctx, kustomization.Ns, pullSecrets); err != nil {
+				return serviceInfoWithError(serviceInfo, err.Error())
+			}
+		}
+	}
+
+	initVariables := createInitVariables(srvMode, releaseInfo)
+	owner := createOwnership(s.instance, serviceInfo.Path, releaseInfo, filedata)
+	owner.pullSecrets = pullSecretNames(pullSecrets)
+
+	if s.audit.enabled() || s.history.enabled() {
+		bh := createBaseHandler(ctx, cluster, nil, kustomization, initVariables, owner)
+		state.handler = &bh
+		state.before, _ = s.findLiveRelease(bh)
+		if s.history.enabled() {
+			if manifest, err := s.appliedManifest(bh); err == nil {
+				state.manifest = manifestHash(manifest)
+			}
+		}
+	}
 
 	if kustomization.Kind == "cronjob" {
-		action, err := s.handleCronjob(ctx, kustomization, recreate, disabled, initVariables)
+		action, err := s.handleCronjob(ctx, cluster, kustomization, recreate, disabled, initVariables, owner)
 		if err != nil {
 			return serviceInfoWithError(serviceInfo, err.Error())
 		}
 		return serviceInfoWithAction(serviceInfo, action)
 	} else if kustomization.Kind == "deployment" {
-		action, err := s.handleDeployment(ctx, kustomization, recreate, disabled, initVariables)
+		action, err := s.handleDeployment(ctx, cluster, kustomization, recreate, disabled, initVariables, owner)
 		if err != nil {
 			return serviceInfoWithError(serviceInfo, err.Error())
 		}
 
 		if kustomization.Service != nil {
-			if err = s.handleService(ctx, kustomization, recreate); err != nil {
+			serviceInfo.ServiceResource, err = s.handleService(ctx, cluster, kustomization, recreate, disabled, owner)
+			if err != nil {
 				return serviceInfoWithError(serviceInfo, err.Error())
 			}
 		}
 		return serviceInfoWithAction(serviceInfo, action)
+	} else if tmpl := s.resourceTemplate(kustomization); tmpl != nil {
+		action, err := s.handleResource(ctx, cluster, kustomization, tmpl, recreate, disabled, initVariables, owner)
+		if err != nil {
+			var conflictErr *ApplyConflictError
+			if errors.As(err, &conflictErr) {
+				serviceInfo.Conflicts = conflictErr.Conflicts
+			}
+			return serviceInfoWithError(serviceInfo, err.Error())
+		}
+		return serviceInfoWithAction(serviceInfo, action)
 	} else {
 		return serviceInfoWithError(serviceInfo, "unknown kind of kustomization")
 	}
 }
 
+// createInitVariables create environment variables of init containers, which load release into pod
+func createInitVariables(srvMode string, releaseInfo *api.ReleaseInfo) []EnvVar {
+	return []EnvVar{
+		{Name: "APP_SERVER_MODE", Value: srvMode},
+		{Name: "APP_RELEASE_TAG", Value: releaseInfo.ImageTag},
+	}
+}
+
+func serverModeName(serverMode api.ServerMode) string {
+	if serverMode == api.ServerMode_Development {
+		return "devel"
+	}
+	return "prod"
+}
+
+func serverMode(name string) api.ServerMode {
+	if name == "devel" {
+		return api.ServerMode_Development
+	}
+	return api.ServerMode_Production
+}
+
+// gitclientFor find git client for provider of kustomization repository
+func (s *deploymentServer) gitclientFor(kustomization *Kustomization) (gitclient.GitClient, error) {
+	gitcli, ok := s.gitclients[kustomization.Repository.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown git provider `%s`", kustomization.Repository.Provider)
+	}
+	return gitcli, nil
+}
+
+// tagPolicy find rules of release tag selection for kustomization repository
+func (s *deploymentServer) tagPolicy(kustomization *Kustomization) *gitclient.TagPolicy {
+	if kustomization.Repository.TagPolicy != nil {
+		return kustomization.Repository.TagPolicy
+	}
+	return s.providers[kustomization.Repository.Provider].TagPolicy
+}
+
+// loadRelease load info about latest release of kustomization repository, matched to tag policy.
+// When release is pinned via `repository.tag` or `repository.version`, pinned release is returned with tag of latest one
+func (s *deploymentServer) loadRelease(gitcli gitclient.GitClient, kustomization *Kustomization, srvMode string) (*api.ReleaseInfo, error) {
+	repo := &kustomization.Repository
+	policy := s.tagPolicy(kustomization)
+
+	if repo.Tag != "" && repo.Version != "" {
+		return nil, errors.New("only one of `repository.tag` and `repository.version` may be declared")
+	}
+
+	if repo.Tag == "" && repo.Version == "" {
+		return loadImageTag(gitcli, repo, srvMode, policy)
+	}
+
+	// latest release is selected with pinned one, pinned release is deployed even if latest one is not found
+	releaseInfo, err := loadImageTag(gitcli, repo, srvMode, policy.Pin(srvMode, repo.Tag, repo.Version))
+	if err != nil {
+		return nil, fmt.Errorf("pinned release: %v", err)
+	}
+	return releaseInfo, nil
+}
+
+func loadImageTag(gitcli gitclient.GitClient, repo *Repository, srvMode string, policy *gitclient.TagPolicy) (*api.ReleaseInfo, error) {
+	releaseInfo, err := gitcli.LoadImageTag(repo.Group, repo.Project, srvMode, policy)
+	if err != nil {
+		return nil, fmt.Errorf("can not load image tag from git: %v", err)
+	}
+
+	if releaseInfo == nil {
+		return nil, errors.New("not found group or project in git")
+	}
+
+	if len(releaseInfo.ImageTag) == 0 {
+		return nil, errors.New("not found image tag in git")
+	}
+	return releaseInfo, nil
+}
+
 func serviceInfoWithError(info *api.ServiceInfo, errorDescription string) *api.ServiceInfo {
 	info.ActionVariants = &api.ServiceInfo_ErrorDescription{
 		ErrorDescription: errorDescription,
@@ -230,27 +461,47 @@ func extrtactArtifactPath(prefixLen int, path, filename string) string {
 	return path
 }
 
-func (s *deploymentServer) handleCronjob(ctx context.Context, kustomization *Kustomization, recreate, disabled bool, initVariables []EnvVar) (api.Action, error) {
+func (s *deploymentServer) handleCronjob(ctx context.Context, cluster *cluster, kustomization *Kustomization, recreate, disabled bool, initVariables []EnvVar, owner ownership) (api.Action, error) {
 	tmpl := s.templates[CronJobKind][""]
-	bh := createBaseHandler(ctx, s, tmpl, kustomization, initVariables)
+	bh := createBaseHandler(ctx, cluster, tmpl, kustomization, initVariables, owner)
 	handler := createCronjobHandler(bh)
 	return handleArtifact(handler, recreate, disabled)
 }
 
-func (s *deploymentServer) handleDeployment(ctx context.Context, kustomization *Kustomization, recreate, disabled bool, initVariables []EnvVar) (api.Action, error) {
-	tier := kustomization.Service.DeploymentTemplate
-	if tier == "" {
-		tier = kustomization.Tier
+// deploymentTemplate find template for deployment by tier or by `service.deployment-template`
+func (s *deploymentServer) deploymentTemplate(kustomization *Kustomization) *template.Template {
+	tier := kustomization.Tier
+	if kustomization.Service != nil && kustomization.Service.DeploymentTemplate != "" {
+		tier = kustomization.Service.DeploymentTemplate
 	}
 
 	log.Printf("find template in : %d %s\n", DeploymentKind, tier)
 
-	tmpl := s.templates[DeploymentKind][tier]
-	bh := createBaseHandler(ctx, s, tmpl, kustomization, initVariables)
+	return s.templates[DeploymentKind][tier]
+}
+
+func (s *deploymentServer) handleDeployment(ctx context.Context, cluster *cluster, kustomization *Kustomization, recreate, disabled bool, initVariables []EnvVar, owner ownership) (api.Action, error) {
+	tmpl := s.deploymentTemplate(kustomization)
+	bh := createBaseHandler(ctx, cluster, tmpl, kustomization, initVariables, owner)
 	handler := createDeploymentHandler(bh)
 	return handleArtifact(handler, recreate, disabled)
 }
 
+// resourceTemplate find template for kind of kustomization that does not have own handler
+func (s *deploymentServer) resourceTemplate(kustomization *Kustomization) *template.Template {
+	resources := s.templates[ResourceKind]
+	if tmpl, ok := resources[kustomization.Kind+"-"+kustomization.Tier]; ok {
+		return tmpl
+	}
+	return resources[kustomization.Kind]
+}
+
+func (s *deploymentServer) handleResource(ctx context.Context, cluster *cluster, kustomization *Kustomization, tmpl *template.Template, recreate, disabled bool, initVariables []EnvVar, owner ownership) (api.Action, error) {
+	bh := createBaseHandler(ctx, cluster, tmpl, kustomization, initVariables, owner)
+	handler := createResourceHandler(bh)
+	return handleArtifact(handler, recreate, disabled)
+}
+
 func handleArtifact(handler artifactHandler, recreate, disabled bool) (api.Action, error) {
 	found, err := handler.Find()
 	if err != nil {
@@ -298,24 +549,46 @@ func handleArtifact(handler artifactHandler, recreate, disabled bool) (api.Actio
 	return api.Action_NotChanged, nil
 }
 
-func (s *deploymentServer) handleService(ctx context.Context, kustomization *Kustomization, recreate bool) error {
+func (s *deploymentServer) handleService(ctx context.Context, cluster *cluster, kustomization *Kustomization, recreate, disabled bool, owner ownership) (*api.ResourceInfo, error) {
 	template := s.templates[ServiceKind][kustomization.Service.ServiceTemplate]
 
 	if template == nil {
 		fmt.Printf("kustomize service %s.%s - %s not found template %s\n", kustomization.Ns, kustomization.Name, kustomization.Tier, kustomization.Service.ServiceTemplate)
-		return fmt.Errorf("kustomize service %s.%s - %s not found template %s", kustomization.Ns, kustomization.Name, kustomization.Tier, kustomization.Service.ServiceTemplate)
+		return nil, fmt.Errorf("kustomize service %s.%s - %s not found template %s", kustomization.Ns, kustomization.Name, kustomization.Tier, kustomization.Service.ServiceTemplate)
 	}
 
 	manifest, err := KustomizeService(kustomization, template)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	fmt.Printf("kustomize service %s.%s - %s with\n%v\n", kustomization.Ns, kustomization.Name, kustomization.Tier, string(manifest))
 
-	if err = s.applyService(ctx, manifest); err != nil {
-		return err
+	if disabled {
+		return cluster.disableService(ctx, manifest, owner)
+	}
+
+	return cluster.applyService(ctx, manifest, recreate, owner)
+}
+
+// disableService remove service of deployment disabled for server mode via `only-for`
+func (c *cluster) disableService(ctx context.Context, manifest []byte, owner ownership) (*api.ResourceInfo, error) {
+	srv, err := decodeService(manifest, owner)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	info := &api.ResourceInfo{Kind: ServiceName, Name: srv.Name, Action: api.Action_NotChanged}
+
+	live, err := c.findService(ctx, srv.Namespace, srv.Name)
+	if err != nil || live == nil {
+		return info, err
+	}
+
+	if err := c.removeService(ctx, live); err != nil {
+		return info, err
+	}
+	log.Printf("service %s.%s of disabled deployment removed\n", live.Namespace, live.Name)
+	info.Action = api.Action_Removed
+	return info, nil
 }
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"demius.md/deployment-operator/api"
+	"demius.md/deployment-operator/utils"
+)
+
+// DiffContextLines is number of unchanged lines around changes in diff
+const DiffContextLines = 3
+
+// Diff compare live objects in k8s with manifests rendered from kustomizations
+func (s *deploymentServer) Diff(ctx context.Context, request *api.Request) (*api.DiffResponse, error) {
+	log.Printf("diff of path `%s` requested\n", request.Path)
+
+	if err := s.authorize(ctx, request); err != nil {
+		return nil, err
+	}
+
+	source := s.kustomizations
+	prefixLen := len(source) + 1
+
+	if len(request.Path) > 0 {
+		source = filepath.Join(source, request.Path)
+	}
+
+	services := make([]*api.ServiceDiff, 0, MaxServicesCount)
+	err := filepath.Walk(source, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walk dir%s: %v", path, err)
+		}
+		if f.IsDir() {
+			return nil
+		}
+
+		services = append(services, s.diffKustomization(ctx, prefixLen, path, request.Mode, request.Cluster))
+
+		if len(services) >= MaxServicesCount {
+			return fmt.Errorf("maximum number of services in one diff call exceeded: %v", MaxServicesCount)
+		}
+		return nil
+	})
+
+	var response = &api.DiffResponse{
+		ResponseVariants: &api.DiffResponse_DiffsResponse{
+			DiffsResponse: &api.DiffsResponse{Services: services},
+		},
+	}
+	return response, err
+}
+
+func (s *deploymentServer) diffKustomization(ctx context.Context, prefixLen int, path string, serverMode api.ServerMode, clusterName string) *api.ServiceDiff {
+	filename := filepath.Base(path)
+
+	serviceDiff := &api.ServiceDiff{
+		Path: extrtactArtifactPath(prefixLen, path, filename),
+	}
+	if filename != "kustomization.yaml" {
+		return serviceDiffWithError(serviceDiff, "file with customization must be `kustomization.yaml`, actual: "+filename)
+	}
+
+	filedata, err := ioutil.ReadFile(path)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, "can not read `kustomization.yaml`: "+err.Error())
+	}
+
+	kustomization, err := ParseKustomization(filedata)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, "can not parse `kustomization.yaml`: "+err.Error())
+	}
+
+	serviceDiff.ServiceId = &api.ServiceID{
+		Group:   kustomization.Repository.Group,
+		Package: kustomization.Name,
+		Kind:    kustomization.Kind,
+	}
+
+	gitcli, err := s.gitclientFor(kustomization)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, err.Error())
+	}
+
+	serviceDiff.Provider = gitcli.ProviderName()
+
+	cluster, err := s.clusterFor(kustomization, clusterName)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, err.Error())
+	}
+
+	serviceDiff.Cluster = cluster.name
+
+	srvMode := serverModeName(serverMode)
+
+	releaseInfo, err := s.loadRelease(gitcli, kustomization, srvMode)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, err.Error())
+	}
+
+	serviceDiff.Release = releaseInfo
+
+	initVariables := createInitVariables(srvMode, releaseInfo)
+	owner := createOwnership(s.instance, serviceDiff.Path, releaseInfo, filedata)
+	owner.pullSecrets = pullSecretNames(s.pullSecretsFor(kustomization))
+	bh := createBaseHandler(ctx, cluster, nil, kustomization, initVariables, owner)
+
+	var diff string
+
+	if kustomization.Kind == "cronjob" {
+		diff, err = s.diffCronjob(bh)
+	} else if kustomization.Kind == "deployment" {
+		diff, err = s.diffDeployment(bh)
+		if err == nil && kustomization.Service != nil {
+			var srvDiff string
+			srvDiff, err = s.diffService(bh)
+			diff += srvDiff
+		}
+	} else if tmpl := s.resourceTemplate(kustomization); tmpl != nil {
+		bh.tmpl = tmpl
+		diff, err = s.diffResource(bh)
+	} else {
+		err = fmt.Errorf("unknown kind of kustomization")
+	}
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, err.Error())
+	}
+
+	// release is read the same way as poller does
+	live, err := s.findLiveRelease(bh)
+	if err != nil {
+		return serviceDiffWithError(serviceDiff, err.Error())
+	}
+	if live != nil {
+		serviceDiff.LiveImageTag = live.tag
+	}
+	serviceDiff.ImageUpToDate = serviceDiff.LiveImageTag == releaseInfo.ImageTag
+	serviceDiff.Drifted = diff != ""
+	serviceDiff.DiffVariants = &api.ServiceDiff_Diff{Diff: diff}
+	return serviceDiff
+}
+
+func serviceDiffWithError(diff *api.ServiceDiff, errorDescription string) *api.ServiceDiff {
+	diff.DiffVariants = &api.ServiceDiff_ErrorDescription{
+		ErrorDescription: errorDescription,
+	}
+	return diff
+}
+
+func (s *deploymentServer) diffCronjob(bh baseHandler) (string, error) {
+	bh.tmpl = s.templates[CronJobKind][""]
+	if bh.tmpl == nil {
+		return "", fmt.Errorf("not found template for cronjob")
+	}
+
+	handler := createCronjobHandler(bh)
+	if err := handler.Kustomize(); err != nil {
+		return "", err
+	}
+	rendered, err := bh.cluster.cronjobObject(handler.manifest, bh.kustomization.Env, bh.initVariables, bh.owner)
+	if err != nil {
+		return "", err
+	}
+	// live cronjob is read without conversion, so fields served only by batch/v1 are compared
+	live, err := bh.cluster.findObject(bh.ctx, rendered)
+	if err != nil {
+		return "", err
+	}
+
+	name := "cronjob/" + rendered.GetNamespace() + "." + rendered.GetName()
+	if live == nil {
+		return diffObjects(name, nil, rendered)
+	}
+	return diffObjects(name, live, rendered)
+}
+
+func (s *deploymentServer) diffDeployment(bh baseHandler) (string, error) {
+	bh.tmpl = s.deploymentTemplate(bh.kustomization)
+	if bh.tmpl == nil {
+		return "", fmt.Errorf("not found template for deployment with tier `%s`", bh.kustomization.Tier)
+	}
+
+	handler := createDeploymentHandler(bh)
+	if _, err := handler.Find(); err != nil {
+		return "", err
+	}
+	if err := handler.Kustomize(); err != nil {
+		return "", err
+	}
+	rendered, err := decodeDeployment(handler.manifest, bh.kustomization.Env, bh.initVariables, bh.owner)
+	if err != nil {
+		return "", err
+	}
+
+	name := "deployment/" + rendered.Namespace + "." + rendered.Name
+	if handler.deployment == nil {
+		return diffObjects(name, nil, rendered)
+	}
+	return diffObjects(name, handler.deployment, rendered)
+}
+
+func (s *deploymentServer) diffService(bh baseHandler) (string, error) {
+	tmpl := s.templates[ServiceKind][bh.kustomization.Service.ServiceTemplate]
+	if tmpl == nil {
+		return "", fmt.Errorf("not found template for service `%s`", bh.kustomization.Service.ServiceTemplate)
+	}
+
+	manifest, err := KustomizeService(bh.kustomization, tmpl)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := decodeService(manifest, bh.owner)
+	if err != nil {
+		return "", err
+	}
+	live, err := bh.cluster.findService(bh.ctx, rendered.Namespace, rendered.Name)
+	if err != nil {
+		return "", err
+	}
+
+	name := "service/" + rendered.Namespace + "." + rendered.Name
+	if live == nil {
+		return diffObjects(name, nil, rendered)
+	}
+	return diffObjects(name, live, rendered)
+}
+
+func (s *deploymentServer) diffResource(bh baseHandler) (string, error) {
+	handler := createResourceHandler(bh)
+	if _, err := handler.Find(); err != nil {
+		return "", err
+	}
+
+	live := make(map[string]runtime.Object)
+	for _, obj := range handler.live {
+		live[objectName(obj)] = obj
+	}
+
+	var diff strings.Builder
+	for _, obj := range handler.objects {
+		objDiff, err := diffObjects(objectName(obj), live[objectName(obj)], obj)
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(objDiff)
+	}
+	return diff.String(), nil
+}
+
+// diffObjects compare live object with rendered one. Only fields existed in rendered object are compared,
+// so fields populated by k8s (status, defaults, metadata of server) are ignored
+func diffObjects(name string, live, rendered runtime.Object) (string, error) {
+	renderedFields, err := objectFields(rendered)
+	if err != nil {
+		return "", err
+	}
+	renderedFields = removeEmptyFields(renderedFields).(map[string]interface{})
+	removeReleaseAnnotations(renderedFields)
+
+	var liveFields interface{}
+	if live != nil {
+		fields, err := objectFields(live)
+		if err != nil {
+			return "", err
+		}
+		liveFields = intersectFields(fields, renderedFields)
+	}
+
+	liveLines, err := yamlLines(liveFields)
+	if err != nil {
+		return "", err
+	}
+	renderedLines, err := yamlLines(renderedFields)
+	if err != nil {
+		return "", err
+	}
+	return utils.UnifiedDiff("live/"+name, "rendered/"+name, liveLines, renderedLines, DiffContextLines), nil
+}
+
+func objectFields(obj runtime.Object) (map[string]interface{}, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil, fmt.Errorf("can not convert object for diff: %v", err)
+	}
+	// typed objects from k8s api do not have apiVersion and kind
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	delete(fields, "status")
+	return fields, nil
+}
+
+// removeEmptyFields remove nulls and empty maps, produced by conversion of typed objects
+func removeEmptyFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			field = removeEmptyFields(field)
+			if m, ok := field.(map[string]interface{}); field == nil || (ok && len(m) == 0) {
+				delete(v, key)
+				continue
+			}
+			v[key] = field
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = removeEmptyFields(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// intersectFields keep only fields of live object which exist in rendered object
+func intersectFields(live, rendered interface{}) interface{} {
+	switch r := rendered.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		result := make(map[string]interface{}, len(r))
+		for key, field := range r {
+			if liveField, ok := l[key]; ok {
+				result[key] = intersectFields(liveField, field)
+			}
+		}
+		return result
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return live
+		}
+		if key := listMergeKey(r); key != "" {
+			return intersectKeyedList(l, r, key)
+		}
+		if len(l) != len(r) {
+			return live
+		}
+		result := make([]interface{}, len(l))
+		for i := range l {
+			result[i] = intersectFields(l[i], r[i])
+		}
+		return result
+	}
+	return live
+}
+
+// listMergeKeys are keys of elements of lists in pod spec and service spec, like patch merge keys of strategic merge
+var listMergeKeys = []string{"name", "containerPort", "port", "mountPath", "devicePath"}
+
+// listMergeKey find merge key, which is declared in all elements of rendered list
+func listMergeKey(rendered []interface{}) string {
+	if len(rendered) == 0 {
+		return ""
+	}
+	for _, key := range listMergeKeys {
+		found := true
+		for _, element := range rendered {
+			m, ok := element.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			if _, ok := m[key]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return key
+		}
+	}
+	return ""
+}
+
+// intersectKeyedList match elements of live list with rendered ones by merge key. Live elements, which are not
+// rendered, are added by k8s or by operator (injected containers, image pull secrets), so they are skipped
+func intersectKeyedList(live, rendered []interface{}, key string) []interface{} {
+	result := make([]interface{}, 0, len(rendered))
+	for _, element := range rendered {
+		value := element.(map[string]interface{})[key]
+		for _, liveElement := range live {
+			if m, ok := liveElement.(map[string]interface{}); ok && fmt.Sprint(m[key]) == fmt.Sprint(value) {
+				result = append(result, intersectFields(m, element))
+				break
+			}
+		}
+	}
+	return result
+}
+
+// removeReleaseAnnotations remove annotations of release applied by operator, they are changed by every release
+// and are reported by live image tag
+func removeReleaseAnnotations(fields map[string]interface{}) {
+	metadata, ok := fields["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(annotations, ReleaseAnnotation)
+	delete(annotations, RevisionAnnotation)
+	delete(annotations, DigestAnnotation)
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+func yamlLines(fields interface{}) ([]string, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal object for diff: %v", err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestIntersectFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     interface{}
+		rendered interface{}
+		expected interface{}
+	}{
+		{
+			name:     "fields of server are skipped",
+			live:     map[string]interface{}{"replicas": 2, "revisionHistoryLimit": 10},
+			rendered: map[string]interface{}{"replicas": 1},
+			expected: map[string]interface{}{"replicas": 2},
+		},
+		{
+			name: "injected containers are skipped",
+			live: []interface{}{
+				map[string]interface{}{"name": "istio-proxy", "image": "proxy"},
+				map[string]interface{}{"name": "app", "image": "app:v2", "imagePullPolicy": "IfNotPresent"},
+			},
+			rendered: []interface{}{map[string]interface{}{"name": "app", "image": "app:v1"}},
+			expected: []interface{}{map[string]interface{}{"name": "app", "image": "app:v2"}},
+		},
+		{
+			name: "ports are matched by container port",
+			live: []interface{}{
+				map[string]interface{}{"containerPort": int64(9090), "protocol": "TCP"},
+				map[string]interface{}{"containerPort": int64(8080), "protocol": "TCP"},
+			},
+			rendered: []interface{}{map[string]interface{}{"containerPort": int64(8080)}},
+			expected: []interface{}{map[string]interface{}{"containerPort": int64(8080)}},
+		},
+		{
+			name:     "missing element is not matched",
+			live:     []interface{}{map[string]interface{}{"name": "registry-a"}},
+			rendered: []interface{}{map[string]interface{}{"name": "registry-b"}},
+			expected: []interface{}{},
+		},
+		{
+			name:     "lists without keys are compared as a whole",
+			live:     []interface{}{"--a", "--b"},
+			rendered: []interface{}{"--a"},
+			expected: []interface{}{"--a", "--b"},
+		},
+	}
+	for _, test := range tests {
+		if result := intersectFields(test.live, test.rendered); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: intersectFields = %v, expected %v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestRemoveReleaseAnnotations(t *testing.T) {
+	fields := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				ReleaseAnnotation:  "v1",
+				RevisionAnnotation: "abc",
+				DigestAnnotation:   "sha256:abc",
+			},
+		},
+	}
+	removeReleaseAnnotations(fields)
+	if expected := map[string]interface{}{"metadata": map[string]interface{}{}}; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("removeReleaseAnnotations = %v, expected %v", fields, expected)
+	}
+}
+
+func TestReleaseTag(t *testing.T) {
+	initContainers := []apiv1.Container{{Name: "load", Env: []apiv1.EnvVar{{Name: "APP_SERVER_MODE", Value: "prod"}, {Name: "APP_RELEASE_TAG", Value: "v1"}}}}
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		initContainers []apiv1.Container
+		tag            string
+	}{
+		{"release annotation", map[string]string{ReleaseAnnotation: "v2"}, initContainers, "v2"},
+		{"environment of init container", nil, initContainers, "v1"},
+		{"image tag is not release", nil, []apiv1.Container{{Name: "load", Image: "loader:v3"}}, ""},
+		{"no init containers", map[string]string{}, nil, ""},
+	}
+	for _, test := range tests {
+		if tag := releaseTag(test.annotations, test.initContainers); tag != test.tag {
+			t.Errorf("%s: releaseTag = %s, expected %s", test.name, tag, test.tag)
+		}
+	}
+}
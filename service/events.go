@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recordEvent create k8s event about action of operator with object, errors are only logged
+func (c *cluster) recordEvent(ctx context.Context, ref *apiv1.ObjectReference, eventType, reason, message string) {
+	now := metav1.Now()
+	event := &apiv1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: ref.Name + ".",
+			Namespace:    ref.Namespace,
+		},
+		InvolvedObject: *ref,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         apiv1.EventSource{Component: ManagedByValue},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := c.clientset.CoreV1().Events(ref.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		log.Printf("can not create event %s for %s %s.%s: %v\n", reason, ref.Kind, ref.Namespace, ref.Name, err)
+	}
+}
+
+// objectReference create reference to object for event
+func objectReference(apiVersion, kind string, meta metav1.Object) *apiv1.ObjectReference {
+	return &apiv1.ObjectReference{
+		APIVersion:      apiVersion,
+		Kind:            kind,
+		Namespace:       meta.GetNamespace(),
+		Name:            meta.GetName(),
+		UID:             meta.GetUID(),
+		ResourceVersion: meta.GetResourceVersion(),
+	}
+}
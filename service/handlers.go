@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"text/template"
 
 	appsv1 "k8s.io/api/apps/v1"
 	apibatch "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type artifactHandler interface {
@@ -19,10 +21,11 @@ type artifactHandler interface {
 
 type baseHandler struct {
 	ctx           context.Context
-	server        *deploymentServer
+	cluster       *cluster
 	tmpl          *template.Template
 	kustomization *Kustomization
 	initVariables []EnvVar
+	owner         ownership
 	manifest      []byte
 }
 
@@ -36,8 +39,15 @@ type deploymentHandler struct {
 	deployment *appsv1.Deployment
 }
 
-func createBaseHandler(ctx context.Context, server *deploymentServer, tmpl *template.Template, kustomization *Kustomization, initVariables []EnvVar) baseHandler {
-	return baseHandler{ctx, server, tmpl, kustomization, initVariables, nil}
+// resourceHandler handle any k8s resources via dynamic client and server-side apply
+type resourceHandler struct {
+	baseHandler
+	objects []*unstructured.Unstructured
+	live    []*unstructured.Unstructured
+}
+
+func createBaseHandler(ctx context.Context, cluster *cluster, tmpl *template.Template, kustomization *Kustomization, initVariables []EnvVar, owner ownership) baseHandler {
+	return baseHandler{ctx, cluster, tmpl, kustomization, initVariables, owner, nil}
 }
 
 func createCronjobHandler(bh baseHandler) *cronjobHandler {
@@ -48,8 +58,12 @@ func createDeploymentHandler(bh baseHandler) *deploymentHandler {
 	return &deploymentHandler{bh, nil}
 }
 
+func createResourceHandler(bh baseHandler) *resourceHandler {
+	return &resourceHandler{bh, nil, nil}
+}
+
 func (c *cronjobHandler) Find() (bool, error) {
-	job, err := c.server.findCronjob(c.ctx, c.kustomization.Ns, c.kustomization.Name, c.kustomization.Tier)
+	job, err := c.cluster.findCronjob(c.ctx, c.kustomization.Ns, c.kustomization.Name, c.kustomization.Tier)
 	if err != nil {
 		return false, err
 	}
@@ -61,7 +75,7 @@ func (c *cronjobHandler) Kustomize() error {
 	if c.manifest != nil {
 		return nil
 	}
-	manifest, err := KustomizeCronJob(c.kustomization, c.tmpl)
+	manifest, err := KustomizeCronJob(c.kustomization, c.tmpl, c.owner.release)
 	if err != nil {
 		return err
 	}
@@ -70,12 +84,21 @@ func (c *cronjobHandler) Kustomize() error {
 }
 
 func (c *cronjobHandler) Create() error {
-	return c.server.createCronjob(c.ctx, c.manifest, c.kustomization.Env, c.initVariables)
+	return c.cluster.createCronjob(c.ctx, c.manifest, c.kustomization.Env, c.initVariables, c.owner)
 }
 
 func (c *cronjobHandler) Update() (bool, error) {
-	repo := &c.kustomization.Repository
-	updated, err := c.server.updateCronjob(c.ctx, c.job, repo)
+	if c.tmpl == nil {
+		return false, fmt.Errorf("not found template for cronjob")
+	}
+	if err := c.Kustomize(); err != nil {
+		return false, err
+	}
+	rendered, err := c.cluster.cronjobObject(c.manifest, c.kustomization.Env, c.initVariables, c.owner)
+	if err != nil {
+		return false, err
+	}
+	updated, err := c.cluster.updateCronjob(c.ctx, c.job, rendered)
 	if err != nil {
 		return false, err
 	}
@@ -83,11 +106,11 @@ func (c *cronjobHandler) Update() (bool, error) {
 }
 
 func (c *cronjobHandler) Remove() error {
-	return c.server.removeCronjob(c.ctx, c.job)
+	return c.cluster.removeCronjob(c.ctx, c.job)
 }
 
 func (c *deploymentHandler) Find() (bool, error) {
-	deployment, err := c.server.findDeployment(c.ctx, c.kustomization.Ns, c.kustomization.Name, c.kustomization.Tier)
+	deployment, err := c.cluster.findDeployment(c.ctx, c.kustomization.Ns, c.kustomization.Name, c.kustomization.Tier)
 	if err != nil {
 		return false, err
 	}
@@ -100,7 +123,7 @@ func (c *deploymentHandler) Kustomize() error {
 		return nil
 	}
 	log.Printf("Kustomize deployment with %v\n", c.tmpl)
-	manifest, err := KustomizeDeployment(c.kustomization, c.tmpl)
+	manifest, err := KustomizeDeployment(c.kustomization, c.tmpl, c.owner.release)
 	if err != nil {
 		return err
 	}
@@ -109,12 +132,21 @@ func (c *deploymentHandler) Kustomize() error {
 }
 
 func (c *deploymentHandler) Create() error {
-	return c.server.createDeployment(c.ctx, c.manifest, c.kustomization.Env, c.initVariables)
+	return c.cluster.createDeployment(c.ctx, c.manifest, c.kustomization.Env, c.initVariables, c.owner)
 }
 
 func (c *deploymentHandler) Update() (bool, error) {
-	repo := &c.kustomization.Repository
-	updated, err := c.server.updateDeployment(c.ctx, c.deployment, repo)
+	if c.tmpl == nil {
+		return false, fmt.Errorf("not found template for deployment with tier `%s`", c.kustomization.Tier)
+	}
+	if err := c.Kustomize(); err != nil {
+		return false, err
+	}
+	rendered, err := decodeDeployment(c.manifest, c.kustomization.Env, c.initVariables, c.owner)
+	if err != nil {
+		return false, err
+	}
+	updated, err := c.cluster.updateDeployment(c.ctx, c.deployment, rendered)
 	if err != nil {
 		return false, err
 	}
@@ -122,5 +154,83 @@ func (c *deploymentHandler) Update() (bool, error) {
 }
 
 func (c *deploymentHandler) Remove() error {
-	return c.server.removeDeployment(c.ctx, c.deployment)
+	return c.cluster.removeDeployment(c.ctx, c.deployment)
+}
+
+func (c *resourceHandler) Find() (bool, error) {
+	// kind and name of objects are known only after kustomization
+	if err := c.Kustomize(); err != nil {
+		return false, err
+	}
+	c.live = nil
+	for _, obj := range c.objects {
+		live, err := c.cluster.findObject(c.ctx, obj)
+		if err != nil {
+			return false, err
+		}
+		if live != nil {
+			c.live = append(c.live, live)
+		}
+	}
+	return len(c.live) > 0, nil
+}
+
+func (c *resourceHandler) Kustomize() error {
+	if c.manifest != nil {
+		return nil
+	}
+	manifest, err := KustomizeResource(c.kustomization, c.tmpl, c.owner.release)
+	if err != nil {
+		return err
+	}
+	objects, err := DecodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(c.kustomization.Ns)
+		}
+		stampObject(obj, c.owner)
+	}
+	c.manifest = manifest
+	c.objects = objects
+	return nil
+}
+
+func (c *resourceHandler) Create() error {
+	for _, obj := range c.objects {
+		if _, err := c.cluster.applyObject(c.ctx, obj, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *resourceHandler) Update() (bool, error) {
+	versions := make(map[string]string)
+	for _, live := range c.live {
+		versions[objectName(live)] = live.GetResourceVersion()
+	}
+
+	updated := false
+	for _, obj := range c.objects {
+		applied, err := c.cluster.applyObject(c.ctx, obj, false)
+		if err != nil {
+			return false, err
+		}
+		if version, ok := versions[objectName(applied)]; !ok || version != applied.GetResourceVersion() {
+			updated = true
+		}
+	}
+	return updated, nil
+}
+
+func (c *resourceHandler) Remove() error {
+	for _, live := range c.live {
+		if err := c.cluster.removeObject(c.ctx, live); err != nil {
+			return err
+		}
+	}
+	return nil
 }
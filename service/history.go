@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"demius.md/deployment-operator/api"
+)
+
+const (
+	// DefaultHistoryLimit is number of entries of history of service, when limit is not declared
+	DefaultHistoryLimit = 20
+	// HistoryPrefix is prefix of name of config map with history of service
+	HistoryPrefix = "deploy-history-"
+	// ServiceAnnotation contains `cluster/group/package/kind` of service in config map with history
+	ServiceAnnotation = "deployment-operator/service"
+	// historyKey is key of entries in config map with history
+	historyKey = "history.json"
+)
+
+// HistoryConf contains settings of history of releases applied to services
+type HistoryConf struct {
+	Limit     int    `yaml:"limit"`     // number of entries per service, default 20, negative disables history
+	Namespace string `yaml:"namespace"` // namespace of config maps with history in default cluster, namespace of operator by default
+}
+
+// historyEntry is release applied to service, entries are stored in config map as JSON array, newest first
+type historyEntry struct {
+	ImageTag     string `json:"image_tag,omitempty"`
+	Time         string `json:"time"`
+	Actor        string `json:"actor"`
+	Action       string `json:"action"`
+	ManifestHash string `json:"manifest_hash,omitempty"`
+	Path         string `json:"path"`
+	Cluster      string `json:"cluster,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
+}
+
+// releaseHistory keeps bounded history of releases of services in config maps of default cluster
+type releaseHistory struct {
+	lock      sync.Mutex
+	limit     int
+	namespace string
+	clientset kubernetes.Interface
+}
+
+func newReleaseHistory(conf HistoryConf, clientset kubernetes.Interface) *releaseHistory {
+	h := &releaseHistory{limit: conf.Limit, namespace: conf.Namespace, clientset: clientset}
+	if h.limit == 0 {
+		h.limit = DefaultHistoryLimit
+	}
+	if h.namespace == "" {
+		h.namespace = operatorNamespace()
+	}
+	return h
+}
+
+// enabled report whether history of releases is written
+func (h *releaseHistory) enabled() bool {
+	return h.limit > 0
+}
+
+// historyName return name of config map with history of service in cluster
+func historyName(clusterName string, id *api.ServiceID) string {
+	hash := sha256.Sum256([]byte(historyKeyOf(clusterName, id)))
+	return HistoryPrefix + hex.EncodeToString(hash[:10])
+}
+
+// historyKeyOf return key of history of service, services with same id in different clusters have own histories
+func historyKeyOf(clusterName string, id *api.ServiceID) string {
+	return clusterName + "/" + serviceKey(id)
+}
+
+func serviceKey(id *api.ServiceID) string {
+	return id.GetGroup() + "/" + id.GetPackage() + "/" + id.GetKind()
+}
+
+// manifestHash return sha256 of applied manifest
+func manifestHash(manifest []byte) string {
+	hash := sha256.Sum256(manifest)
+	return hex.EncodeToString(hash[:])
+}
+
+// load read entries of history of service in cluster, newest first
+func (h *releaseHistory) load(ctx context.Context, clusterName string, id *api.ServiceID) ([]historyEntry, error) {
+	name := historyName(clusterName, id)
+	configMap, err := h.clientset.CoreV1().ConfigMaps(h.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get history `%s`, got error '%v'", name, err)
+	}
+	return decodeHistory(configMap)
+}
+
+func decodeHistory(configMap *apiv1.ConfigMap) ([]historyEntry, error) {
+	data, ok := configMap.Data[historyKey]
+	if !ok {
+		return nil, nil
+	}
+	var entries []historyEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("can not parse history `%s`: %v", configMap.Name, err)
+	}
+	return entries, nil
+}
+
+// add insert entry into history of service in cluster and drop oldest entries above limit
+func (h *releaseHistory) add(ctx context.Context, clusterName string, id *api.ServiceID, entry historyEntry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	name := historyName(clusterName, id)
+	configMaps := h.clientset.CoreV1().ConfigMaps(h.namespace)
+
+	// config map may be updated by other replica of operator
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		live, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		found := err == nil
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("could not get history `%s`, got error '%v'", name, err)
+		}
+
+		var entries []historyEntry
+		if found {
+			if entries, err = decodeHistory(live); err != nil {
+				// broken history is replaced
+				log.Printf("%v\n", err)
+			}
+		}
+		entries = append([]historyEntry{entry}, entries...)
+		if len(entries) > h.limit {
+			entries = entries[:h.limit]
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			created := &apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   h.namespace,
+					Labels:      map[string]string{ManagedByLabel: ManagedByValue},
+					Annotations: map[string]string{ServiceAnnotation: historyKeyOf(clusterName, id)},
+				},
+				Data: map[string]string{historyKey: string(data)},
+			}
+			if _, err := configMaps.Create(ctx, created, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("history create error '%s'", err.Error())
+			}
+			return nil
+		}
+
+		updated := live.DeepCopy()
+		updated.Data = map[string]string{historyKey: string(data)}
+		_, err = configMaps.Update(ctx, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// recordHistory add applied release of kustomization into history, failed and not changed deployments are not recorded
+func (s *deploymentServer) recordHistory(ctx context.Context, serviceInfo *api.ServiceInfo, state *serviceAudit) {
+	if !s.history.enabled() || serviceInfo.GetErrorDescription() != "" || serviceInfo.GetServiceId() == nil {
+		return
+	}
+	action := serviceInfo.GetAction()
+	if action == api.Action_NotChanged {
+		return
+	}
+
+	entry := historyEntry{
+		ImageTag:     serviceInfo.GetRelease().GetImageTag(),
+		Time:         time.Now().Format(time.RFC3339),
+		Actor:        actor(ctx),
+		Action:       action.String(),
+		ManifestHash: state.manifest,
+		Path:         serviceInfo.Path,
+		Cluster:      serviceInfo.Cluster,
+		Namespace:    state.namespace,
+	}
+	if action == api.Action_Removed {
+		// tag of removed release
+		entry.ImageTag, entry.ManifestHash = "", ""
+		if state.before != nil {
+			entry.ImageTag = state.before.tag
+		}
+	}
+
+	if err := s.history.add(ctx, serviceInfo.Cluster, serviceInfo.ServiceId, entry); err != nil {
+		log.Printf("can not record history of %s: %v\n", historyKeyOf(serviceInfo.Cluster, serviceInfo.ServiceId), err)
+	}
+}
+
+// History return releases applied to service in all clusters, newest first. Entries out of scope of client are skipped
+func (s *deploymentServer) History(ctx context.Context, id *api.ServiceID) (*api.HistoryResponse, error) {
+	log.Printf("history of %s/%s requested\n", id.GetPackage(), id.GetKind())
+
+	if !s.history.enabled() {
+		return historyError("history of releases is disabled"), nil
+	}
+	if id.GetPackage() == "" || id.GetKind() == "" {
+		return historyError("package and kind of service must be declared"), nil
+	}
+
+	client := clientIdentity(ctx)
+	if client != nil && len(client.conf.Paths) == 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "identity %s is not allowed to read history of any path", client.name)
+	}
+
+	var entries []historyEntry
+	for _, clusterName := range s.clusterNames() {
+		if !client.allowsCluster(clusterName) {
+			continue
+		}
+		clusterEntries, err := s.history.load(ctx, clusterName, id)
+		if err != nil {
+			return historyError(err.Error()), nil
+		}
+		entries = append(entries, clusterEntries...)
+	}
+	sortHistory(entries)
+
+	result := make([]*api.HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !(client.allowsPath(entry.Path) && client.allowsNamespace(entry.Namespace) && client.allowsCluster(entry.Cluster)) {
+			continue
+		}
+		result = append(result, &api.HistoryEntry{
+			ImageTag:     entry.ImageTag,
+			Time:         entry.Time,
+			Actor:        entry.Actor,
+			Action:       api.Action(api.Action_value[entry.Action]),
+			ManifestHash: entry.ManifestHash,
+			Path:         entry.Path,
+			Cluster:      entry.Cluster,
+			Namespace:    entry.Namespace,
+		})
+	}
+
+	return &api.HistoryResponse{
+		ResponseVariants: &api.HistoryResponse_HistoryResponse{
+			HistoryResponse: &api.HistoryEntries{Entries: result},
+		},
+	}, nil
+}
+
+// sortHistory sort entries of several clusters by time, newest first
+func sortHistory(entries []historyEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		first, _ := time.Parse(time.RFC3339, entries[i].Time)
+		second, _ := time.Parse(time.RFC3339, entries[j].Time)
+		return first.After(second)
+	})
+}
+
+func historyError(errorDesc string) *api.HistoryResponse {
+	return &api.HistoryResponse{
+		ResponseVariants: &api.HistoryResponse_ErrorDescription{
+			ErrorDescription: errorDesc,
+		},
+	}
+}
+
+// appliedManifest render main object of kustomization with init variables, ownership and pull secrets,
+// as it is applied by handlers, for hash in history
+func (s *deploymentServer) appliedManifest(bh baseHandler) ([]byte, error) {
+	switch bh.kustomization.Kind {
+	case "cronjob":
+		if bh.tmpl = s.templates[CronJobKind][""]; bh.tmpl == nil {
+			return nil, fmt.Errorf("not found template for cronjob")
+		}
+		handler := createCronjobHandler(bh)
+		if err := handler.Kustomize(); err != nil {
+			return nil, err
+		}
+		job, err := bh.cluster.cronjobObject(handler.manifest, bh.kustomization.Env, bh.initVariables, bh.owner)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(job.Object)
+	case "deployment":
+		if bh.tmpl = s.deploymentTemplate(bh.kustomization); bh.tmpl == nil {
+			return nil, fmt.Errorf("not found template for deployment with tier `%s`", bh.kustomization.Tier)
+		}
+		handler := createDeploymentHandler(bh)
+		if err := handler.Kustomize(); err != nil {
+			return nil, err
+		}
+		deployment, err := decodeDeployment(handler.manifest, bh.kustomization.Env, bh.initVariables, bh.owner)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(deployment)
+	}
+	if bh.tmpl = s.resourceTemplate(bh.kustomization); bh.tmpl == nil {
+		return nil, fmt.Errorf("unknown kind of kustomization")
+	}
+	handler := createResourceHandler(bh)
+	if err := handler.Kustomize(); err != nil {
+		return nil, err
+	}
+	objects := make([]interface{}, 0, len(handler.objects))
+	for _, obj := range handler.objects {
+		objects = append(objects, obj.Object)
+	}
+	return json.Marshal(objects)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"demius.md/deployment-operator/api"
+)
+
+func TestReleaseHistoryByCluster(t *testing.T) {
+	history := newReleaseHistory(HistoryConf{Limit: 2, Namespace: "ops"}, fake.NewSimpleClientset())
+	id := &api.ServiceID{Group: "group", Package: "app", Kind: "deployment"}
+	ctx := context.Background()
+
+	for _, tag := range []string{"v1", "v2", "v3"} {
+		if err := history.add(ctx, "east", id, historyEntry{ImageTag: tag}); err != nil {
+			t.Fatalf("add error: %v", err)
+		}
+	}
+	if err := history.add(ctx, "west", id, historyEntry{ImageTag: "v1"}); err != nil {
+		t.Fatalf("add error: %v", err)
+	}
+
+	east, err := history.load(ctx, "east", id)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if expected := []historyEntry{{ImageTag: "v3"}, {ImageTag: "v2"}}; !reflect.DeepEqual(east, expected) {
+		t.Errorf("history of east = %v, expected %v", east, expected)
+	}
+	west, err := history.load(ctx, "west", id)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if expected := []historyEntry{{ImageTag: "v1"}}; !reflect.DeepEqual(west, expected) {
+		t.Errorf("history of west = %v, expected %v", west, expected)
+	}
+}
+
+func TestSortHistory(t *testing.T) {
+	entries := []historyEntry{
+		{ImageTag: "v1", Time: "2021-03-01T10:00:00Z"},
+		{ImageTag: "v3", Time: "2021-03-01T13:00:00+02:00"},
+		{ImageTag: "v2", Time: "2021-03-01T10:30:00Z"},
+	}
+	sortHistory(entries)
+	tags := []string{entries[0].ImageTag, entries[1].ImageTag, entries[2].ImageTag}
+	if expected := []string{"v3", "v2", "v1"}; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("sorted history = %v, expected %v", tags, expected)
+	}
+}
+
+func TestHistoryScope(t *testing.T) {
+	s := &deploymentServer{history: newReleaseHistory(HistoryConf{Limit: 2, Namespace: "ops"}, fake.NewSimpleClientset())}
+	id := &api.ServiceID{Group: "group", Package: "app", Kind: "deployment"}
+
+	tests := []struct {
+		name   string
+		client *identity
+		code   codes.Code
+	}{
+		{name: "api is not protected", code: codes.OK},
+		{name: "all paths", client: &identity{name: "ci", conf: Identity{Paths: []string{AllowAll}}}, code: codes.OK},
+		{name: "empty paths", client: &identity{name: "ci", conf: Identity{}}, code: codes.PermissionDenied},
+	}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.client != nil {
+			ctx = context.WithValue(ctx, identityKey{}, test.client)
+		}
+		_, err := s.History(ctx, id)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%s: History error code = %v, expected %v", test.name, code, test.code)
+		}
+	}
+}
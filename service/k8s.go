@@ -5,14 +5,20 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	apibatch "k8s.io/api/batch/v1beta1"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+
+	"demius.md/deployment-operator/api"
 )
 
 // ManifestType enum of manifest file types
@@ -27,15 +33,19 @@ const (
 	ServiceManifest
 )
 
+const (
+	// ServiceDeleteTimeout is maximum time of waiting for deletion of recreated service, e.g. with finalizers of load balancer
+	ServiceDeleteTimeout = 2 * time.Minute
+)
+
 // FindCronjob find allready existed cronjob with namespace ns
-func (s *deploymentServer) findCronjob(ctx context.Context, ns, name, tier string) (*apibatch.CronJob, error) {
+func (c *cluster) findCronjob(ctx context.Context, ns, name, tier string) (*apibatch.CronJob, error) {
 	// log.Println("find cronjob " + ns + " : " + name + "." + tier)
 	log.Println("find cronjob " + ns + " : " + name)
-	batchAPI := s.clientset.BatchV1beta1()
-	apiJobs := batchAPI.CronJobs(ns)
+	apiJobs := c.cronjobAPI()
 
-	// cronjob, err := apiJobs.Get(ctx, name+"."+tier, metav1.GetOptions{})
-	cronjob, err := apiJobs.Get(ctx, name, metav1.GetOptions{})
+	// cronjob, err := apiJobs.Get(ctx, ns, name+"."+tier)
+	cronjob, err := apiJobs.Get(ctx, ns, name)
 	if err != nil {
 		switch t := err.(type) {
 		case *errors.StatusError:
@@ -52,50 +62,32 @@ func (s *deploymentServer) findCronjob(ctx context.Context, ns, name, tier strin
 	return cronjob, nil
 }
 
-// CreateCronjob create new cronjob from manifest
-func (s *deploymentServer) createCronjob(ctx context.Context, manifest []byte, env []EnvVar, initVariables []EnvVar) error {
-	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
-
-	j := &apibatch.CronJob{}
-
-	if err := decoder.Decode(&j); err != nil {
+// CreateCronjob create new cronjob from manifest via server-side apply
+func (c *cluster) createCronjob(ctx context.Context, manifest []byte, env []EnvVar, initVariables []EnvVar, owner ownership) error {
+	obj, err := c.cronjobObject(manifest, env, initVariables, owner)
+	if err != nil {
 		return err
 	}
-
-	if len(env) > 0 {
-		containers := j.Spec.JobTemplate.Spec.Template.Spec.Containers
-		applyEnvironment(containers, env)
-	}
-
-	initContainers := j.Spec.JobTemplate.Spec.Template.Spec.InitContainers
-	if len(initContainers) > 0 {
-		fmt.Println("job " + j.Namespace + "." + j.Name + " has initContainers")
-		applyEnvironment(initContainers, initVariables)
-	} else {
-		fmt.Println("job " + j.Namespace + "." + j.Name + " has not initContainers; bug in config")
-	}
-
-	batchAPI := s.clientset.BatchV1beta1()
-	apiJobs := batchAPI.CronJobs(j.Namespace)
-
-	if _, err := apiJobs.Create(ctx, j, metav1.CreateOptions{}); err != nil {
+	if _, err := c.applyObject(ctx, obj, false); err != nil {
 		return fmt.Errorf("job create error '%s'", err.Error())
 	}
 	return nil
 }
 
-// UpdateCronjob update allready existed cronjob with new image
-func (s *deploymentServer) updateCronjob(ctx context.Context, job *apibatch.CronJob, repo *Repository) (bool, error) {
-	containers := job.Spec.JobTemplate.Spec.Template.Spec.InitContainers
-
-	if len(containers) > 0 {
-		fmt.Println("job " + job.Namespace + "." + job.Name + " has initContainers")
-	} else {
+// UpdateCronjob apply rendered cronjob to allready existed one via server-side apply and restart its running pods
+func (c *cluster) updateCronjob(ctx context.Context, job *apibatch.CronJob, rendered *unstructured.Unstructured) (bool, error) {
+	initContainers, _, _ := unstructured.NestedSlice(rendered.Object, "spec", "jobTemplate", "spec", "template", "spec", "initContainers")
+	if len(initContainers) == 0 {
 		fmt.Println("job " + job.Namespace + "." + job.Name + " has not initContainers; can not update")
 	}
 
+	if _, err := c.applyObject(ctx, rendered, false); err != nil {
+		return false, err
+	}
+
+	// running pods of job are restarted only after template is applied, so new pods load new release
 	var grace int64 = 5
-	podsAPI := s.clientset.CoreV1().Pods(job.Namespace)
+	podsAPI := c.clientset.CoreV1().Pods(job.Namespace)
 	if err := podsAPI.DeleteCollection(
 		ctx, metav1.DeleteOptions{GracePeriodSeconds: &grace},
 		metav1.ListOptions{LabelSelector: "sia-app=" + job.Name}); err != nil {
@@ -106,11 +98,10 @@ func (s *deploymentServer) updateCronjob(ctx context.Context, job *apibatch.Cron
 }
 
 // RemoveCronjob remove cronjob from k8s
-func (s *deploymentServer) removeCronjob(ctx context.Context, job *apibatch.CronJob) error {
-	batchAPI := s.clientset.BatchV1beta1()
-	apiJobs := batchAPI.CronJobs(job.Namespace)
+func (c *cluster) removeCronjob(ctx context.Context, job *apibatch.CronJob) error {
+	apiJobs := c.cronjobAPI()
 
-	if err := apiJobs.Delete(ctx, job.Name, metav1.DeleteOptions{}); err != nil {
+	if err := apiJobs.Delete(ctx, job.Namespace, job.Name); err != nil {
 		return fmt.Errorf("cronjob delete error `%v`", err)
 	}
 
@@ -118,10 +109,10 @@ func (s *deploymentServer) removeCronjob(ctx context.Context, job *apibatch.Cron
 }
 
 // FindDeployment find allready existed deployment with namespace ns
-func (s *deploymentServer) findDeployment(ctx context.Context, ns, name, tier string) (*appsv1.Deployment, error) {
+func (c *cluster) findDeployment(ctx context.Context, ns, name, tier string) (*appsv1.Deployment, error) {
 	// log.Println("find deployment " + ns + " : " + name + "." + tier)
 	log.Println("find deployment " + ns + " : " + name)
-	appsAPI := s.clientset.AppsV1()
+	appsAPI := c.clientset.AppsV1()
 	apiDeployments := appsAPI.Deployments(ns)
 
 	// deployment, err := apiDeployments.Get(ctx, name+"."+tier, metav1.GetOptions{})
@@ -143,8 +134,8 @@ func (s *deploymentServer) findDeployment(ctx context.Context, ns, name, tier st
 }
 
 // RemoveDeployment remove deployment from k8s
-func (s *deploymentServer) removeDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
-	appsAPI := s.clientset.AppsV1()
+func (c *cluster) removeDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
+	appsAPI := c.clientset.AppsV1()
 	apiDeployments := appsAPI.Deployments(deployment.Namespace)
 
 	if err := apiDeployments.Delete(ctx, deployment.Name, metav1.DeleteOptions{}); err != nil {
@@ -154,14 +145,61 @@ func (s *deploymentServer) removeDeployment(ctx context.Context, deployment *app
 	return nil
 }
 
-// CreateDeployment create new deployment from manifest
-func (s *deploymentServer) createDeployment(ctx context.Context, manifest []byte, env []EnvVar, initVariables []EnvVar) error {
+// CreateDeployment create new deployment from manifest via server-side apply
+func (c *cluster) createDeployment(ctx context.Context, manifest []byte, env []EnvVar, initVariables []EnvVar, owner ownership) error {
+	d, err := decodeDeployment(manifest, env, initVariables, owner)
+	if err != nil {
+		return err
+	}
+
+	obj, err := typedObject(d, "apps/v1", "Deployment")
+	if err != nil {
+		return err
+	}
+	if _, err := c.applyObject(ctx, obj, false); err != nil {
+		return fmt.Errorf("deployment create error '%s'", err.Error())
+	}
+
+	return nil
+}
+
+// decodeCronjob decode cronjob from manifest and apply environment variables
+func decodeCronjob(manifest []byte, env []EnvVar, initVariables []EnvVar, owner ownership) (*apibatch.CronJob, error) {
+	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
+
+	j := &apibatch.CronJob{}
+
+	if err := decoder.Decode(&j); err != nil {
+		return nil, err
+	}
+
+	if len(env) > 0 {
+		containers := j.Spec.JobTemplate.Spec.Template.Spec.Containers
+		applyEnvironment(containers, env)
+	}
+
+	initContainers := j.Spec.JobTemplate.Spec.Template.Spec.InitContainers
+	if len(initContainers) > 0 {
+		fmt.Println("job " + j.Namespace + "." + j.Name + " has initContainers")
+		applyEnvironment(initContainers, initVariables)
+	} else {
+		fmt.Println("job " + j.Namespace + "." + j.Name + " has not initContainers; bug in config")
+	}
+
+	applyPullSecrets(&j.Spec.JobTemplate.Spec.Template.Spec, owner.pullSecrets)
+	stampObjectMeta(&j.ObjectMeta, owner)
+
+	return j, nil
+}
+
+// decodeDeployment decode deployment from manifest and apply environment variables
+func decodeDeployment(manifest []byte, env []EnvVar, initVariables []EnvVar, owner ownership) (*appsv1.Deployment, error) {
 	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
 
 	d := &appsv1.Deployment{}
 
 	if err := decoder.Decode(&d); err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(env) > 0 {
@@ -177,14 +215,28 @@ func (s *deploymentServer) createDeployment(ctx context.Context, manifest []byte
 		fmt.Println("deployment " + d.Namespace + "." + d.Name + " has not initContainers; bug in config")
 	}
 
-	appsAPI := s.clientset.AppsV1()
-	apiDeployments := appsAPI.Deployments(d.Namespace)
+	applyPullSecrets(&d.Spec.Template.Spec, owner.pullSecrets)
+	stampObjectMeta(&d.ObjectMeta, owner)
 
-	if _, err := apiDeployments.Create(ctx, d, metav1.CreateOptions{}); err != nil {
-		return fmt.Errorf("deployment create error '%s'", err.Error())
+	return d, nil
+}
+
+// decodeService decode service from manifest, service is stamped without annotations of release
+func decodeService(manifest []byte, owner ownership) (*apiv1.Service, error) {
+	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
+
+	srv := &apiv1.Service{}
+
+	if err := decoder.Decode(&srv); err != nil {
+		return nil, err
 	}
 
-	return nil
+	stampObjectMeta(&srv.ObjectMeta, owner)
+	// service does not depend on release, so it is not changed by every deploy
+	delete(srv.Annotations, ReleaseAnnotation)
+	delete(srv.Annotations, DigestAnnotation)
+
+	return srv, nil
 }
 
 func applyEnvironment(containers []apiv1.Container, env []EnvVar) {
@@ -245,19 +297,23 @@ func applyEnvironment(containers []apiv1.Container, env []EnvVar) {
 	containers[0].Env = append(containers[0].Env, cenv...)
 }
 
-// TODO: UpdateDeployment via remove and create new pods !!!
-// UpdateDeployment update allready existed deployment with new image
-func (s *deploymentServer) updateDeployment(ctx context.Context, deployment *appsv1.Deployment, repo *Repository) (bool, error) {
-	containers := deployment.Spec.Template.Spec.InitContainers
-
-	if len(containers) > 0 {
-		fmt.Println("deployment " + deployment.Namespace + "." + deployment.Name + " has initContainers")
-	} else {
+// UpdateDeployment apply rendered deployment to allready existed one via server-side apply and restart its pods
+func (c *cluster) updateDeployment(ctx context.Context, deployment *appsv1.Deployment, rendered *appsv1.Deployment) (bool, error) {
+	if len(rendered.Spec.Template.Spec.InitContainers) == 0 {
 		fmt.Println("deployment " + deployment.Namespace + "." + deployment.Name + " has not initContainers; can not update")
 	}
 
+	obj, err := typedObject(rendered, "apps/v1", "Deployment")
+	if err != nil {
+		return false, err
+	}
+	if _, err := c.applyObject(ctx, obj, false); err != nil {
+		return false, err
+	}
+
+	// pods are restarted after template is applied, so they load release even if template is not changed
 	var grace int64 = 5
-	podsAPI := s.clientset.CoreV1().Pods(deployment.Namespace)
+	podsAPI := c.clientset.CoreV1().Pods(deployment.Namespace)
 	if err := podsAPI.DeleteCollection(
 		ctx,
 		metav1.DeleteOptions{GracePeriodSeconds: &grace},
@@ -268,26 +324,101 @@ func (s *deploymentServer) updateDeployment(ctx context.Context, deployment *app
 	return true, nil
 }
 
-// ApplyService create new service if not exists
-func (s *deploymentServer) applyService(ctx context.Context, manifest []byte) error {
-	decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
+// ApplyService create new service or update allready existed one via server-side apply, recreate it if requested.
+// Allocated clusterIP and node ports of existed service are not owned by operator and are preserved on update
+func (c *cluster) applyService(ctx context.Context, manifest []byte, recreate bool, owner ownership) (*api.ResourceInfo, error) {
+	srv, err := decodeService(manifest, owner)
+	if err != nil {
+		return nil, err
+	}
 
-	srv := &apiv1.Service{}
+	println("     applyService " + srv.Namespace + ":" + srv.Name)
 
-	if err := decoder.Decode(&srv); err != nil {
-		return err
+	info := &api.ResourceInfo{Kind: ServiceName, Name: srv.Name, Action: api.Action_NotChanged}
+
+	live, err := c.findService(ctx, srv.Namespace, srv.Name)
+	if err != nil {
+		return info, err
 	}
 
-	println("     applyService " + srv.Namespace + ":" + srv.Name)
+	if live != nil && recreate {
+		if err := c.removeService(ctx, live); err != nil {
+			return info, err
+		}
+		// service with finalizers is not deleted immediately
+		apiServices := c.clientset.CoreV1().Services(srv.Namespace)
+		err := wait.PollImmediate(time.Second, ServiceDeleteTimeout, func() (bool, error) {
+			_, err := apiServices.Get(ctx, srv.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		})
+		if err != nil {
+			return info, fmt.Errorf("service `%s` is not deleted for recreation: %v", srv.Name, err)
+		}
+	}
 
-	api := s.clientset.CoreV1()
-	apiServices := api.Services(srv.Namespace)
-	if _, err := apiServices.Get(ctx, srv.Name, metav1.GetOptions{}); err != nil {
-		// create service
-		log.Printf("Error getting service: %v\n", err)
-		if _, err := apiServices.Create(ctx, srv, metav1.CreateOptions{}); err != nil {
-			return fmt.Errorf("service create error '%s'", err.Error())
+	srv.Spec.Ports = servicePorts(srv.Spec.Ports)
+	obj, err := typedObject(srv, "v1", "Service")
+	if err != nil {
+		return info, err
+	}
+	applied, err := c.applyObject(ctx, obj, false)
+	if err != nil {
+		return info, err
+	}
+
+	switch {
+	case live == nil:
+		info.Action = api.Action_Created
+	case recreate:
+		info.Action = api.Action_Recreated
+	case live.ResourceVersion != applied.GetResourceVersion():
+		info.Action = api.Action_Updated
+	}
+	return info, nil
+}
+
+// servicePorts fill defaults of ports from manifest, so applied ports are not changed by defaulting of k8s
+func servicePorts(ports []apiv1.ServicePort) []apiv1.ServicePort {
+	result := make([]apiv1.ServicePort, len(ports))
+	for i, port := range ports {
+		if port.Protocol == "" {
+			port.Protocol = apiv1.ProtocolTCP
+		}
+		if port.TargetPort.IntVal == 0 && port.TargetPort.StrVal == "" {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		result[i] = port
+	}
+	return result
+}
+
+// FindService find allready existed service with namespace ns
+func (c *cluster) findService(ctx context.Context, ns, name string) (*apiv1.Service, error) {
+	log.Println("find service " + ns + " : " + name)
+	api := c.clientset.CoreV1()
+	apiServices := api.Services(ns)
+
+	srv, err := apiServices.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
 		}
+		return nil, fmt.Errorf("could not get service `%s`, got error '%v'", name, err)
 	}
+	return srv, nil
+}
+
+// RemoveService remove service from k8s
+func (c *cluster) removeService(ctx context.Context, srv *apiv1.Service) error {
+	api := c.clientset.CoreV1()
+	apiServices := api.Services(srv.Namespace)
+
+	if err := apiServices.Delete(ctx, srv.Name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("service delete error `%v`", err)
+	}
+
 	return nil
 }
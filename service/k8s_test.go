@@ -0,0 +1,35 @@
+package service
+
+import (
+	"testing"
+
+	"demius.md/deployment-operator/api"
+)
+
+func TestDecodeService(t *testing.T) {
+	manifest := []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: app\n  namespace: dev\n  annotations:\n    team: core\n")
+	tests := []struct {
+		name    string
+		release *api.ReleaseInfo
+	}{
+		{name: "release tag", release: &api.ReleaseInfo{ImageTag: "v1"}},
+		{name: "release digest", release: &api.ReleaseInfo{ImageTag: "v2", ImageDigest: "sha256:abc"}},
+	}
+	for _, test := range tests {
+		srv, err := decodeService(manifest, createOwnership("test", "dev/app", test.release, manifest))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		for _, key := range []string{ReleaseAnnotation, DigestAnnotation} {
+			if value, ok := srv.Annotations[key]; ok {
+				t.Errorf("%s: service has annotation %s=%s", test.name, key, value)
+			}
+		}
+		for _, key := range []string{"team", KustomizationAnnotation, RevisionAnnotation} {
+			if _, ok := srv.Annotations[key]; !ok {
+				t.Errorf("%s: service has not annotation %s", test.name, key)
+			}
+		}
+	}
+}
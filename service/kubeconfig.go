@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"demius.md/deployment-operator/utils"
+)
+
+// KubeConf contains settings of connection to k8s api, when operator runs outside of cluster
+type KubeConf struct {
+	Kubeconfig string `yaml:"kubeconfig"` // path of kubeconfig, default files of env KUBECONFIG or `~/.kube/config`
+	Context    string `yaml:"context"`    // context of kubeconfig, default current context
+}
+
+// restConfig create config of k8s client. In-cluster config is used, when neither kubeconfig nor context
+// is declared and operator runs in pod, otherwise kubeconfig is loaded with precedence of kubectl
+func (c KubeConf) restConfig() (*rest.Config, error) {
+	if c.Kubeconfig == "" && c.Context == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
+		}
+		if err != rest.ErrNotInCluster {
+			return nil, err
+		}
+	}
+
+	// env KUBECONFIG may contain list of files, which are merged
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if c.Kubeconfig != "" {
+		if !utils.FileExists(c.Kubeconfig) {
+			return nil, fmt.Errorf("operator runs outside of cluster and kubeconfig `%s` does not exist", c.Kubeconfig)
+		}
+		rules.ExplicitPath = c.Kubeconfig
+	}
+	log.Printf("load kubeconfig %s, context: %s\n", rules.GetDefaultFilename(), c.Context)
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("can not load kubeconfig: %v", err)
+	}
+	return config, nil
+}
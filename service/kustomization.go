@@ -6,6 +6,9 @@ import (
 	"text/template"
 
 	yaml "gopkg.in/yaml.v2"
+
+	"demius.md/deployment-operator/api"
+	"demius.md/deployment-operator/gitclient"
 )
 
 // Kustomization of k8s manifests
@@ -19,14 +22,19 @@ type Kustomization struct {
 	Repository Repository `yaml:"repository"`
 	Schedule   string     `yaml:"schedule"`
 	Env        []EnvVar   `yaml:"env"`
+	AutoDeploy *bool      `yaml:"auto-deploy"` // default true, false disables deployment by webhooks and poller
+	Cluster    string     `yaml:"cluster"`     // bind kustomization to cluster, default cluster of request
 }
 
 // Repository is a Gitlab registry details
 type Repository struct {
-	Provider string `yaml:"provider"`
-	Group    string `yaml:"group"`
-	Project  string `yaml:"project"`
-	Path     string `yaml:"path"`
+	Provider  string               `yaml:"provider"`
+	Group     string               `yaml:"group"`
+	Project   string               `yaml:"project"`
+	Path      string               `yaml:"path"`
+	TagPolicy *gitclient.TagPolicy `yaml:"tag-policy"` // overrides tag policy of provider
+	Tag       string               `yaml:"tag"`        // pin release with fixed tag
+	Version   string               `yaml:"version"`    // pin release in range of versions, e.g. `~1.4`
 }
 
 // Service details for proxy-manager
@@ -70,27 +78,31 @@ func ParseKustomization(content []byte) (*Kustomization, error) {
 }
 
 type cronJobData struct {
-	Ns       string
-	Tier     string
-	Name     string
-	Group    string
-	Project  string
-	Schedule string
-	Path     string
+	Ns          string
+	Tier        string
+	Name        string
+	Group       string
+	Project     string
+	Schedule    string
+	Path        string
+	ImageTag    string
+	ImageDigest string
 }
 
 // KustomizeCronJob generate cronjob manifest for k8s
-func KustomizeCronJob(kustomization *Kustomization, tmpl *template.Template) ([]byte, error) {
+func KustomizeCronJob(kustomization *Kustomization, tmpl *template.Template, release *api.ReleaseInfo) ([]byte, error) {
 	repo := &kustomization.Repository
 
 	data := cronJobData{
-		Ns:       kustomization.Ns,
-		Tier:     kustomization.Tier,
-		Name:     kustomization.Name,
-		Schedule: kustomization.Schedule,
-		Group:    repo.Group,
-		Project:  repo.Project,
-		Path:     repo.Path,
+		Ns:          kustomization.Ns,
+		Tier:        kustomization.Tier,
+		Name:        kustomization.Name,
+		Schedule:    kustomization.Schedule,
+		Group:       repo.Group,
+		Project:     repo.Project,
+		Path:        repo.Path,
+		ImageTag:    release.GetImageTag(),
+		ImageDigest: release.GetImageDigest(),
 	}
 
 	manifestBuffer := new(bytes.Buffer)
@@ -102,12 +114,14 @@ func KustomizeCronJob(kustomization *Kustomization, tmpl *template.Template) ([]
 }
 
 type deploymentData struct {
-	Ns      string
-	Tier    string
-	Name    string
-	Group   string
-	Project string
-	Path    string
+	Ns          string
+	Tier        string
+	Name        string
+	Group       string
+	Project     string
+	Path        string
+	ImageTag    string
+	ImageDigest string
 }
 
 // example of github image:
@@ -118,16 +132,18 @@ type deploymentData struct {
 // base image name: sia-cronjobs-efacturi-client
 
 // KustomizeDeployment generate cronjob manifest for k8s
-func KustomizeDeployment(kustomization *Kustomization, tmpl *template.Template) ([]byte, error) {
+func KustomizeDeployment(kustomization *Kustomization, tmpl *template.Template, release *api.ReleaseInfo) ([]byte, error) {
 	repo := &kustomization.Repository
 
 	data := deploymentData{
-		Ns:      kustomization.Ns,
-		Tier:    kustomization.Tier,
-		Name:    kustomization.Name,
-		Group:   repo.Group,
-		Project: repo.Project,
-		Path:    repo.Path,
+		Ns:          kustomization.Ns,
+		Tier:        kustomization.Tier,
+		Name:        kustomization.Name,
+		Group:       repo.Group,
+		Project:     repo.Project,
+		Path:        repo.Path,
+		ImageTag:    release.GetImageTag(),
+		ImageDigest: release.GetImageDigest(),
 	}
 
 	manifestBuffer := new(bytes.Buffer)
@@ -162,3 +178,41 @@ func KustomizeService(kustomization *Kustomization, tmpl *template.Template) ([]
 	}
 	return manifestBuffer.Bytes(), nil
 }
+
+type resourceData struct {
+	Ns          string
+	Tier        string
+	Name        string
+	Kind        string
+	Group       string
+	Project     string
+	Schedule    string
+	Path        string
+	ImageTag    string
+	ImageDigest string
+}
+
+// KustomizeResource generate manifest of any k8s resource
+func KustomizeResource(kustomization *Kustomization, tmpl *template.Template, release *api.ReleaseInfo) ([]byte, error) {
+	repo := &kustomization.Repository
+
+	data := resourceData{
+		Ns:          kustomization.Ns,
+		Tier:        kustomization.Tier,
+		Name:        kustomization.Name,
+		Kind:        kustomization.Kind,
+		Schedule:    kustomization.Schedule,
+		Group:       repo.Group,
+		Project:     repo.Project,
+		Path:        repo.Path,
+		ImageTag:    release.GetImageTag(),
+		ImageDigest: release.GetImageDigest(),
+	}
+
+	manifestBuffer := new(bytes.Buffer)
+	err := tmpl.Execute(manifestBuffer, data)
+	if err != nil {
+		return nil, fmt.Errorf("can not apply variables to %s template: %v", kustomization.Kind, err)
+	}
+	return manifestBuffer.Bytes(), nil
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"demius.md/deployment-operator/api"
+)
+
+const (
+	// NamespaceName is kind of namespace in deploy response
+	NamespaceName = "namespace"
+	// BootstrapName is name of ResourceQuota and LimitRange created by operator in namespace
+	BootstrapName = "deployment-operator"
+	// ServiceAccountTimeout is time of waiting for default service account of created namespace
+	ServiceAccountTimeout = 10 * time.Second
+)
+
+// NamespaceConf contains settings of namespaces created by operator for kustomizations
+type NamespaceConf struct {
+	Create           bool              `yaml:"create"`             // create missing namespaces, namespaces are not managed by default
+	Labels           map[string]string `yaml:"labels"`             // labels of created namespace
+	Annotations      map[string]string `yaml:"annotations"`        // annotations of created namespace
+	ResourceQuota    map[string]string `yaml:"resource-quota"`     // hard limits of quota, e.g. `requests.cpu: "4"`
+	LimitRange       *LimitRangeConf   `yaml:"limit-range"`        // defaults of containers
+	ImagePullSecrets []string          `yaml:"image-pull-secrets"` // secrets copied from namespace of operator in default cluster and added to default service account
+}
+
+// LimitRangeConf contains default resources of containers in namespace
+type LimitRangeConf struct {
+	Default        map[string]string `yaml:"default"`         // default limits, e.g. `memory: 512Mi`
+	DefaultRequest map[string]string `yaml:"default-request"` // default requests
+	Max            map[string]string `yaml:"max"`
+	Min            map[string]string `yaml:"min"`
+}
+
+// Validate check quantities of quota and limit range
+func (c NamespaceConf) Validate() error {
+	if _, err := resourceList(c.ResourceQuota); err != nil {
+		return fmt.Errorf("resource-quota: %v", err)
+	}
+	if c.LimitRange != nil {
+		for name, resources := range map[string]map[string]string{
+			"default":         c.LimitRange.Default,
+			"default-request": c.LimitRange.DefaultRequest,
+			"max":             c.LimitRange.Max,
+			"min":             c.LimitRange.Min,
+		} {
+			if _, err := resourceList(resources); err != nil {
+				return fmt.Errorf("limit-range: %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureNamespace create missing namespace of kustomization with quota, limits and pull secrets.
+// Pull secrets are read from namespace of operator by clientset of default cluster.
+// Bootstrap of namespaces created by operator is repeated until it succeeds, for recreated namespace
+// and after change of settings of namespaces. Info of namespace is returned only when it was created
+func (c *cluster) ensureNamespace(ctx context.Context, conf NamespaceConf, ns string, source kubernetes.Interface) (*api.ResourceInfo, error) {
+	if !conf.Create {
+		return nil, nil
+	}
+
+	var info *api.ResourceInfo
+	namespaces := c.clientset.CoreV1().Namespaces()
+
+	live, err := namespaces.Get(ctx, ns, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		namespace := &apiv1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        ns,
+				Labels:      mergeStrings(map[string]string{ManagedByLabel: ManagedByValue}, conf.Labels),
+				Annotations: conf.Annotations,
+			},
+		}
+		live, err = namespaces.Create(ctx, namespace, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("namespace create error '%s'", err.Error())
+		}
+		log.Printf("namespace %s created\n", ns)
+		info = &api.ResourceInfo{Kind: NamespaceName, Name: ns, Action: api.Action_Created}
+	case err != nil:
+		return nil, fmt.Errorf("could not get namespace `%s`, got error '%v'", ns, err)
+	case live.Labels[ManagedByLabel] != ManagedByValue:
+		// namespace is not created by operator, so it is not changed
+		return nil, nil
+	}
+
+	// namespace is bootstraped once for its uid and revision of settings
+	checked := string(live.UID) + "/" + namespaceRevision(conf)
+	if previous, ok := c.namespaces.Load(ns); ok && previous == checked {
+		return info, nil
+	}
+	c.namespaces.Delete(ns)
+
+	if err := c.updateNamespace(ctx, conf, live); err != nil {
+		return info, err
+	}
+	if err := c.bootstrapNamespace(ctx, conf, ns, source); err != nil {
+		return info, fmt.Errorf("bootstrap of namespace `%s`: %v", ns, err)
+	}
+	c.namespaces.Store(ns, checked)
+	return info, nil
+}
+
+// namespaceRevision return hash of settings of namespaces
+func namespaceRevision(conf NamespaceConf) string {
+	data, _ := json.Marshal(conf)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// updateNamespace update labels and annotations of namespace created by operator, when they are changed in settings
+func (c *cluster) updateNamespace(ctx context.Context, conf NamespaceConf, live *apiv1.Namespace) error {
+	updated := live.DeepCopy()
+	updated.Labels = mergeStrings(updated.Labels, conf.Labels)
+	updated.Annotations = mergeStrings(updated.Annotations, conf.Annotations)
+	if equality.Semantic.DeepEqual(live.ObjectMeta, updated.ObjectMeta) {
+		return nil
+	}
+	if _, err := c.clientset.CoreV1().Namespaces().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("namespace update error '%s'", err.Error())
+	}
+	log.Printf("namespace %s updated\n", live.Name)
+	return nil
+}
+
+// bootstrapNamespace create quota, limit range and pull secrets or update them, when they are managed by operator
+func (c *cluster) bootstrapNamespace(ctx context.Context, conf NamespaceConf, ns string, source kubernetes.Interface) error {
+	meta := metav1.ObjectMeta{
+		Name:      BootstrapName,
+		Namespace: ns,
+		Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+	}
+
+	if len(conf.ResourceQuota) > 0 {
+		hard, err := resourceList(conf.ResourceQuota)
+		if err != nil {
+			return err
+		}
+		quotas := c.clientset.CoreV1().ResourceQuotas(ns)
+		live, err := quotas.Get(ctx, BootstrapName, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			quota := &apiv1.ResourceQuota{ObjectMeta: meta, Spec: apiv1.ResourceQuotaSpec{Hard: hard}}
+			if _, err := quotas.Create(ctx, quota, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("resource quota create error '%s'", err.Error())
+			}
+		case err != nil:
+			return fmt.Errorf("could not get resource quota, got error '%v'", err)
+		case live.Labels[ManagedByLabel] == ManagedByValue && !equality.Semantic.DeepEqual(live.Spec.Hard, hard):
+			updated := live.DeepCopy()
+			updated.Spec.Hard = hard
+			if _, err := quotas.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("resource quota update error '%s'", err.Error())
+			}
+		}
+	}
+
+	if conf.LimitRange != nil {
+		item := apiv1.LimitRangeItem{Type: apiv1.LimitTypeContainer}
+		var err error
+		if item.Default, err = resourceList(conf.LimitRange.Default); err != nil {
+			return err
+		}
+		if item.DefaultRequest, err = resourceList(conf.LimitRange.DefaultRequest); err != nil {
+			return err
+		}
+		if item.Max, err = resourceList(conf.LimitRange.Max); err != nil {
+			return err
+		}
+		if item.Min, err = resourceList(conf.LimitRange.Min); err != nil {
+			return err
+		}
+		spec := apiv1.LimitRangeSpec{Limits: []apiv1.LimitRangeItem{item}}
+		limitRanges := c.clientset.CoreV1().LimitRanges(ns)
+		live, err := limitRanges.Get(ctx, BootstrapName, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			limits := &apiv1.LimitRange{ObjectMeta: meta, Spec: spec}
+			if _, err := limitRanges.Create(ctx, limits, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("limit range create error '%s'", err.Error())
+			}
+		case err != nil:
+			return fmt.Errorf("could not get limit range, got error '%v'", err)
+		case live.Labels[ManagedByLabel] == ManagedByValue && !equality.Semantic.DeepEqual(live.Spec, spec):
+			updated := live.DeepCopy()
+			updated.Spec = spec
+			if _, err := limitRanges.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("limit range update error '%s'", err.Error())
+			}
+		}
+	}
+
+	if len(conf.ImagePullSecrets) > 0 {
+		return c.copyPullSecrets(ctx, conf.ImagePullSecrets, ns, source)
+	}
+	return nil
+}
+
+// copyPullSecrets copy image pull secrets from namespace of operator in source cluster and add them to default service account
+func (c *cluster) copyPullSecrets(ctx context.Context, names []string, ns string, source kubernetes.Interface) error {
+	secrets := c.clientset.CoreV1().Secrets(ns)
+	sourceSecrets := source.CoreV1().Secrets(operatorNamespace())
+
+	for _, name := range names {
+		secret, err := sourceSecrets.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not get image pull secret `%s`, got error '%v'", name, err)
+		}
+		copied := &apiv1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+			},
+			Type: secret.Type,
+			Data: secret.Data,
+		}
+		live, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			if _, err := secrets.Create(ctx, copied, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("image pull secret create error '%s'", err.Error())
+			}
+		case err != nil:
+			return fmt.Errorf("could not get image pull secret `%s` in namespace `%s`, got error '%v'", name, ns, err)
+		case live.Labels[ManagedByLabel] == ManagedByValue && !reflect.DeepEqual(live.Data, secret.Data):
+			updated := live.DeepCopy()
+			updated.Data = secret.Data
+			if _, err := secrets.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("image pull secret update error '%s'", err.Error())
+			}
+		}
+	}
+
+	// default service account is created by controller of k8s after creation of namespace
+	accounts := c.clientset.CoreV1().ServiceAccounts(ns)
+	var account *apiv1.ServiceAccount
+	err := wait.PollImmediate(time.Second, ServiceAccountTimeout, func() (bool, error) {
+		var err error
+		account, err = accounts.Get(ctx, "default", metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("could not get default service account, got error '%v'", err)
+	}
+
+	updated := account.DeepCopy()
+	for _, name := range names {
+		if !hasPullSecret(updated.ImagePullSecrets, name) {
+			updated.ImagePullSecrets = append(updated.ImagePullSecrets, apiv1.LocalObjectReference{Name: name})
+		}
+	}
+	if len(updated.ImagePullSecrets) == len(account.ImagePullSecrets) {
+		return nil
+	}
+	if _, err := accounts.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("service account update error '%s'", err.Error())
+	}
+	return nil
+}
+
+func hasPullSecret(secrets []apiv1.LocalObjectReference, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceList parse quantities of resources
+func resourceList(resources map[string]string) (apiv1.ResourceList, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	list := make(apiv1.ResourceList, len(resources))
+	for name, value := range resources {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of %s: %v", name, err)
+		}
+		list[apiv1.ResourceName(name)] = quantity
+	}
+	return list, nil
+}
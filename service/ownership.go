@@ -0,0 +1,286 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"demius.md/deployment-operator/api"
+	"demius.md/deployment-operator/utils"
+)
+
+const (
+	// ManagedByLabel is label of all k8s objects created by operator
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// ManagedByValue is value of ManagedByLabel for objects created by operator
+	ManagedByValue = "deployment-operator"
+	// InstanceLabel contains identifier of operator instance, which created object. Only own objects are pruned
+	InstanceLabel = "deployment-operator/instance"
+	// KustomizationAnnotation contains path of kustomization relative to kustomizations dir
+	KustomizationAnnotation = "deployment-operator/kustomization"
+	// ReleaseAnnotation contains release tag applied by operator
+	ReleaseAnnotation = "deployment-operator/release"
+	// RevisionAnnotation contains hash of `kustomization.yaml` applied by operator
+	RevisionAnnotation = "deployment-operator/config-revision"
+	// DigestAnnotation contains digest of release image, when it is resolved by provider
+	DigestAnnotation = "deployment-operator/digest"
+)
+
+// ownership contains info for stamping objects managed by operator
+type ownership struct {
+	instance    string
+	path        string
+	release     *api.ReleaseInfo
+	revision    string
+	pullSecrets []apiv1.LocalObjectReference // image pull secrets of provider, injected into pod spec
+}
+
+func createOwnership(instance, path string, release *api.ReleaseInfo, kustomization []byte) ownership {
+	hash := sha256.Sum256(kustomization)
+	return ownership{instance, path, release, hex.EncodeToString(hash[:]), nil}
+}
+
+func (o ownership) labels() map[string]string {
+	return map[string]string{ManagedByLabel: ManagedByValue, InstanceLabel: o.instance}
+}
+
+func (o ownership) annotations() map[string]string {
+	annotations := map[string]string{
+		KustomizationAnnotation: o.path,
+		ReleaseAnnotation:       o.release.GetImageTag(),
+		RevisionAnnotation:      o.revision,
+	}
+	if digest := o.release.GetImageDigest(); digest != "" {
+		annotations[DigestAnnotation] = digest
+	}
+	return annotations
+}
+
+// stampObjectMeta add labels and annotations of ownership to metadata of typed object
+func stampObjectMeta(meta *metav1.ObjectMeta, owner ownership) {
+	meta.Labels = mergeStrings(meta.Labels, owner.labels())
+	meta.Annotations = mergeStrings(meta.Annotations, owner.annotations())
+}
+
+// stampObject add labels and annotations of ownership to metadata of unstructured object
+func stampObject(obj *unstructured.Unstructured, owner ownership) {
+	obj.SetLabels(mergeStrings(obj.GetLabels(), owner.labels()))
+	obj.SetAnnotations(mergeStrings(obj.GetAnnotations(), owner.annotations()))
+}
+
+func mergeStrings(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// managedObject is object created by operator, found in k8s
+type managedObject struct {
+	kind   string
+	meta   metav1.Object
+	remove func(ctx context.Context) error
+}
+
+// listManagedObjects find all deployments, cronjobs, services and objects of generic kinds created by instance of operator
+func (c *cluster) listManagedObjects(ctx context.Context, instance string) ([]managedObject, error) {
+	opts := metav1.ListOptions{LabelSelector: ManagedByLabel + "=" + ManagedByValue + "," + InstanceLabel + "=" + instance}
+	var objects []managedObject
+
+	deployments, err := c.clientset.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not list managed deployments: %v", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		objects = append(objects, managedObject{DeploymentName, d, func(ctx context.Context) error {
+			return c.removeDeployment(ctx, d)
+		}})
+	}
+
+	jobs, err := c.cronjobAPI().List(ctx, metav1.NamespaceAll, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not list managed cronjobs: %v", err)
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		objects = append(objects, managedObject{CronJobName, j, func(ctx context.Context) error {
+			return c.removeCronjob(ctx, j)
+		}})
+	}
+
+	services, err := c.clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not list managed services: %v", err)
+	}
+	for i := range services.Items {
+		srv := &services.Items[i]
+		objects = append(objects, managedObject{ServiceName, srv, func(ctx context.Context) error {
+			return c.removeService(ctx, srv)
+		}})
+	}
+
+	// objects of generic kinds may be the same as typed ones, e.g. service declared by resource template
+	listed := make(map[types.UID]bool, len(objects))
+	for _, obj := range objects {
+		listed[obj.meta.GetUID()] = true
+	}
+	c.kinds.Range(func(key, value interface{}) bool {
+		mapping := value.(*meta.RESTMapping)
+		var list *unstructured.UnstructuredList
+		if list, err = c.dynamic.Resource(mapping.Resource).List(ctx, opts); err != nil {
+			err = fmt.Errorf("could not list managed %s: %v", mapping.Resource.String(), err)
+			return false
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if listed[obj.GetUID()] {
+				continue
+			}
+			listed[obj.GetUID()] = true
+			objects = append(objects, managedObject{strings.ToLower(obj.GetKind()), obj, func(ctx context.Context) error {
+				return c.removeObject(ctx, obj)
+			}})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// resolveTemplateKinds resolve kinds of objects of resource templates, so objects of deleted kustomizations
+// are pruned, even if kind was not applied since start of operator. Templates are rendered with empty kustomization
+func (s *deploymentServer) resolveTemplateKinds(c *cluster) {
+	for name, tmpl := range s.templates[ResourceKind] {
+		manifest, err := KustomizeResource(&Kustomization{}, tmpl, nil)
+		if err != nil {
+			log.Printf("can not render template %s for kinds of resources: %v\n", name, err)
+			continue
+		}
+		decoder := k8sYaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 1000)
+		for {
+			obj := &unstructured.Unstructured{}
+			if err := decoder.Decode(&obj.Object); err != nil {
+				if err != io.EOF {
+					log.Printf("can not decode template %s for kinds of resources: %v\n", name, err)
+				}
+				break
+			}
+			if obj.GetKind() == "" {
+				continue
+			}
+			if _, err := c.resourceFor(obj); err != nil {
+				log.Printf("template %s: %v\n", name, err)
+			}
+		}
+	}
+}
+
+// pruneApplications remove managed objects under path, which `kustomization.yaml` was deleted.
+// Objects are removed from all clusters, when cluster is not declared. Prune is refused, when there are
+// no kustomizations under path, e.g. directory of kustomizations is not mounted
+func (s *deploymentServer) pruneApplications(ctx context.Context, path, clusterName string) ([]*api.ServiceInfo, error) {
+	if !hasKustomizations(filepath.Join(s.kustomizations, path)) {
+		return nil, fmt.Errorf("no kustomizations found under `%s`, prune is refused", path)
+	}
+
+	var services []*api.ServiceInfo
+	for name, cluster := range s.clusters {
+		if clusterName != "" && name != clusterName {
+			continue
+		}
+		if !clientIdentity(ctx).allowsCluster(name) {
+			// clusters out of scope of client are not pruned
+			continue
+		}
+		removed, err := s.pruneCluster(ctx, cluster, path)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", name, err)
+		}
+		services = append(services, removed...)
+	}
+	return services, nil
+}
+
+func (s *deploymentServer) pruneCluster(ctx context.Context, cluster *cluster, path string) ([]*api.ServiceInfo, error) {
+	s.resolveTemplateKinds(cluster)
+	objects, err := cluster.listManagedObjects(ctx, s.instance)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Clean(path)
+	var services []*api.ServiceInfo
+
+	for _, obj := range objects {
+		kustomizationPath := obj.meta.GetAnnotations()[KustomizationAnnotation]
+		if kustomizationPath == "" {
+			continue
+		}
+		if path != "" && kustomizationPath != prefix && !strings.HasPrefix(kustomizationPath, prefix+string(filepath.Separator)) {
+			continue
+		}
+		if utils.FileExists(filepath.Join(s.kustomizations, kustomizationPath, "kustomization.yaml")) {
+			continue
+		}
+
+		log.Printf("prune %s %s.%s of deleted kustomization %s\n", obj.kind, obj.meta.GetNamespace(), obj.meta.GetName(), kustomizationPath)
+
+		serviceInfo := &api.ServiceInfo{
+			Path: kustomizationPath,
+			ServiceId: &api.ServiceID{
+				Package: obj.meta.GetName(),
+				Kind:    obj.kind,
+			},
+			Cluster: cluster.name,
+		}
+		if id := clientIdentity(ctx); !id.allowsNamespace(obj.meta.GetNamespace()) {
+			services = append(services, serviceInfoWithError(serviceInfo,
+				fmt.Sprintf("identity %s is not allowed to prune namespace %s", id.name, obj.meta.GetNamespace())))
+			continue
+		}
+		if err := obj.remove(ctx); err != nil {
+			services = append(services, serviceInfoWithError(serviceInfo, err.Error()))
+			continue
+		}
+		services = append(services, serviceInfoWithAction(serviceInfo, api.Action_Removed))
+	}
+
+	return services, nil
+}
+
+// hasKustomizations report whether directory contains at least one `kustomization.yaml`
+func hasKustomizations(source string) bool {
+	found := errors.New("found")
+	err := filepath.Walk(source, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !f.IsDir() && f.Name() == "kustomization.yaml" {
+			return found
+		}
+		return nil
+	})
+	return err == found
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"demius.md/deployment-operator/api"
+)
+
+// unknownRelease is reported for objects, which are not deployed by operator
+const unknownRelease = "unknown"
+
+// PollerConf contains settings of periodic deployment of new releases
+type PollerConf struct {
+	Intervals map[string]time.Duration `yaml:"intervals"` // interval of polling per server mode: devel or prod, mode is not polled without interval
+	Clusters  map[string]string        `yaml:"clusters"`  // cluster of kustomizations not bound to cluster per server mode, default-cluster by default
+}
+
+// liveRelease is release of service running in k8s
+type liveRelease struct {
+	tag     string
+	ref     *apiv1.ObjectReference
+	cluster *cluster
+}
+
+// StartPoller start periodic deployment of new releases for every server mode with declared interval
+func (s *deploymentServer) StartPoller(ctx context.Context, conf PollerConf) {
+	for mode, interval := range conf.Intervals {
+		if interval <= 0 {
+			continue
+		}
+		clusterName := s.resolveCluster(conf.Clusters[mode])
+		log.Printf("poll new releases for mode %s in cluster %s every %v\n", mode, clusterName, interval)
+		go s.poll(withActor(ctx, "poller"), serverMode(mode), clusterName, interval)
+	}
+}
+
+func (s *deploymentServer) poll(ctx context.Context, mode api.ServerMode, clusterName string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.pollReleases(ctx, mode, clusterName); err != nil {
+				log.Printf("poll new releases for mode %s: %v\n", serverModeName(mode), err)
+			}
+		}
+	}
+}
+
+// pollReleases update services of cluster, which release in k8s differs from latest release in git
+func (s *deploymentServer) pollReleases(ctx context.Context, mode api.ServerMode, clusterName string) error {
+	prefixLen := len(s.kustomizations) + 1
+
+	return filepath.Walk(s.kustomizations, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walk dir%s: %v", path, err)
+		}
+		if f.IsDir() || filepath.Base(path) != "kustomization.yaml" {
+			return nil
+		}
+
+		live, owner, err := s.checkRelease(ctx, prefixLen, path, mode, clusterName)
+		if err != nil {
+			log.Printf("poll %s: %v\n", path, err)
+			return nil
+		}
+		release := owner.release.GetImageTag()
+		if live == nil || live.tag == release {
+			return nil
+		}
+		previous := live.tag
+		if previous == "" {
+			// object is not deployed by operator, so running release is unknown and latest one is deployed
+			previous = unknownRelease
+		}
+
+		log.Printf("poll %s: release %s changed to %s\n", path, previous, release)
+		serviceInfo := s.handleKustomization(ctx, prefixLen, path, false, mode, live.cluster.name)
+
+		if errorDescription := serviceInfo.GetErrorDescription(); errorDescription != "" {
+			live.cluster.recordEvent(ctx, live.ref, apiv1.EventTypeWarning, "AutoDeployFailed",
+				fmt.Sprintf("deployment of release %s failed: %s", release, errorDescription))
+			return nil
+		}
+		live.cluster.recordEvent(ctx, live.ref, apiv1.EventTypeNormal, "AutoDeploy",
+			fmt.Sprintf("release %s changed to %s: %s", previous, release, serviceInfo.GetAction()))
+		return nil
+	})
+}
+
+// checkRelease find release of service running in k8s and latest release in git.
+// Nil live release is returned, when service is not deployed, is excluded from polling or is bound to other cluster
+func (s *deploymentServer) checkRelease(ctx context.Context, prefixLen int, path string, mode api.ServerMode, clusterName string) (*liveRelease, ownership, error) {
+	filedata, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, ownership{}, err
+	}
+	kustomization, err := ParseKustomization(filedata)
+	if err != nil {
+		return nil, ownership{}, err
+	}
+
+	srvMode := serverModeName(mode)
+	if kustomization.AutoDeploy != nil && !*kustomization.AutoDeploy {
+		return nil, ownership{}, nil
+	}
+	if !(kustomization.OnlyFor == "" || kustomization.OnlyFor == "all" || kustomization.OnlyFor == srvMode) {
+		return nil, ownership{}, nil
+	}
+	if kustomization.Cluster != "" && kustomization.Cluster != clusterName {
+		return nil, ownership{}, nil
+	}
+
+	gitcli, err := s.gitclientFor(kustomization)
+	if err != nil {
+		return nil, ownership{}, err
+	}
+	cluster, err := s.clusterFor(kustomization, clusterName)
+	if err != nil {
+		return nil, ownership{}, err
+	}
+	releaseInfo, err := s.loadRelease(gitcli, kustomization, srvMode)
+	if err != nil {
+		return nil, ownership{}, err
+	}
+
+	owner := createOwnership(s.instance, extrtactArtifactPath(prefixLen, path, filepath.Base(path)), releaseInfo, filedata)
+	bh := createBaseHandler(ctx, cluster, nil, kustomization, createInitVariables(srvMode, releaseInfo), owner)
+
+	live, err := s.findLiveRelease(bh)
+	return live, owner, err
+}
+
+// releaseTag return release from annotation of object, release of objects deployed before ownership annotations
+// is read from environment variable of init container, which loads release into pod
+func releaseTag(annotations map[string]string, initContainers []apiv1.Container) string {
+	if tag := annotations[ReleaseAnnotation]; tag != "" {
+		return tag
+	}
+	if len(initContainers) == 0 {
+		return ""
+	}
+	for _, env := range initContainers[0].Env {
+		if env.Name == "APP_RELEASE_TAG" {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// findLiveRelease find release applied by operator to main object of kustomization
+func (s *deploymentServer) findLiveRelease(bh baseHandler) (*liveRelease, error) {
+	switch bh.kustomization.Kind {
+	case "cronjob":
+		job, err := bh.cluster.findCronjob(bh.ctx, bh.kustomization.Ns, bh.kustomization.Name, bh.kustomization.Tier)
+		if err != nil || job == nil {
+			return nil, err
+		}
+		tag := releaseTag(job.Annotations, job.Spec.JobTemplate.Spec.Template.Spec.InitContainers)
+		return &liveRelease{tag, objectReference(bh.cluster.cronjobAPI().APIVersion(), "CronJob", job), bh.cluster}, nil
+
+	case "deployment":
+		deployment, err := bh.cluster.findDeployment(bh.ctx, bh.kustomization.Ns, bh.kustomization.Name, bh.kustomization.Tier)
+		if err != nil || deployment == nil {
+			return nil, err
+		}
+		tag := releaseTag(deployment.Annotations, deployment.Spec.Template.Spec.InitContainers)
+		return &liveRelease{tag, objectReference("apps/v1", "Deployment", deployment), bh.cluster}, nil
+	}
+
+	bh.tmpl = s.resourceTemplate(bh.kustomization)
+	if bh.tmpl == nil {
+		return nil, fmt.Errorf("unknown kind of kustomization")
+	}
+	handler := createResourceHandler(bh)
+	if _, err := handler.Find(); err != nil || len(handler.live) == 0 {
+		return nil, err
+	}
+	obj := handler.live[0]
+	return &liveRelease{obj.GetAnnotations()[ReleaseAnnotation], objectReference(obj.GetAPIVersion(), obj.GetKind(), obj), bh.cluster}, nil
+}
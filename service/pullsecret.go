@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"demius.md/deployment-operator/gitclient"
+)
+
+// PullSecretPrefix is prefix of name of image pull secret of provider, full name is `registry-<provider>`
+const PullSecretPrefix = "registry-"
+
+// RegistryCredentials contains credentials of image registry of provider,
+// which are propagated into namespaces of kustomizations as image pull secret
+type RegistryCredentials struct {
+	Server       string        `yaml:"server"`        // host of registry, e.g. docker.pkg.github.com, host of provider for registry provider
+	Username     string        `yaml:"username"`      // user of registry
+	Password     string        `yaml:"password"`      // password of registry, secret token of provider by default
+	PasswordFile string        `yaml:"password-file"` // file with password, re-read for every deployment
+	PasswordEnv  string        `yaml:"password-env"`  // environment variable with password
+	PasswordRef  *SecretKeyRef `yaml:"password-ref"`  // key of k8s secret with password
+}
+
+// pullSecret is image pull secret of provider
+type pullSecret struct {
+	name     string
+	server   string
+	username string
+	password gitclient.TokenSource
+}
+
+// pullSecret create image pull secret of provider, nil is returned when registry credentials are not declared
+func (c ProviderConfig) pullSecret(provider string, clientset kubernetes.Interface) (*pullSecret, error) {
+	creds := c.Registry
+	if creds == nil {
+		return nil, nil
+	}
+
+	server := creds.Server
+	if server == "" && c.Type == "registry" {
+		server = provider
+	}
+	if server == "" || creds.Username == "" {
+		return nil, fmt.Errorf("server and username of `registry-credentials` must be declared")
+	}
+
+	password, err := newTokenSource("password", creds.Password, creds.PasswordFile, creds.PasswordEnv, creds.PasswordRef, clientset)
+	if err != nil {
+		return nil, fmt.Errorf("registry-credentials: %v", err)
+	}
+	if password == nil {
+		password, err = c.tokenSource(clientset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if password == nil {
+		return nil, fmt.Errorf("password of `registry-credentials` or secret token of provider must be declared")
+	}
+
+	name := PullSecretPrefix + strings.NewReplacer("_", "-", ":", "-", "/", "-").Replace(strings.ToLower(provider))
+	return &pullSecret{name, server, creds.Username, password}, nil
+}
+
+// dockerConfig create content of secret of type `kubernetes.io/dockerconfigjson`
+func (p *pullSecret) dockerConfig() ([]byte, error) {
+	password, err := p.password.Token()
+	if err != nil {
+		return nil, err
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(p.username + ":" + password))
+	return json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			p.server: map[string]string{"username": p.username, "password": password, "auth": auth},
+		},
+	})
+}
+
+// pullSecretsFor return image pull secrets of provider of kustomization
+func (s *deploymentServer) pullSecretsFor(kustomization *Kustomization) []*pullSecret {
+	if secret, ok := s.pullSecrets[kustomization.Repository.Provider]; ok {
+		return []*pullSecret{secret}
+	}
+	return nil
+}
+
+// ensurePullSecrets create image pull secrets in namespace or refresh them, when credentials were changed
+func (c *cluster) ensurePullSecrets(ctx context.Context, ns string, secrets []*pullSecret) error {
+	apiSecrets := c.clientset.CoreV1().Secrets(ns)
+
+	for _, secret := range secrets {
+		data, err := secret.dockerConfig()
+		if err != nil {
+			return fmt.Errorf("can not load registry credentials: %v", err)
+		}
+
+		live, err := apiSecrets.Get(ctx, secret.name, metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("could not get image pull secret `%s`, got error '%v'", secret.name, err)
+			}
+			created := &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      secret.name,
+					Namespace: ns,
+					Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+				},
+				Type: apiv1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{apiv1.DockerConfigJsonKey: data},
+			}
+			if _, err := apiSecrets.Create(ctx, created, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("image pull secret create error '%s'", err.Error())
+			}
+			log.Printf("image pull secret %s.%s created\n", ns, secret.name)
+			continue
+		}
+
+		if live.Labels[ManagedByLabel] != ManagedByValue {
+			// secret with the same name is created by user, operator must not overwrite it
+			log.Printf("image pull secret %s.%s is not managed by operator, it is not refreshed\n", ns, secret.name)
+			continue
+		}
+		if bytes.Equal(live.Data[apiv1.DockerConfigJsonKey], data) {
+			continue
+		}
+		updated := live.DeepCopy()
+		updated.Data = map[string][]byte{apiv1.DockerConfigJsonKey: data}
+		if _, err := apiSecrets.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("image pull secret update error '%s'", err.Error())
+		}
+		log.Printf("image pull secret %s.%s refreshed\n", ns, secret.name)
+	}
+	return nil
+}
+
+// pullSecretNames return references to image pull secrets
+func pullSecretNames(secrets []*pullSecret) []apiv1.LocalObjectReference {
+	var refs []apiv1.LocalObjectReference
+	for _, secret := range secrets {
+		refs = append(refs, apiv1.LocalObjectReference{Name: secret.name})
+	}
+	return refs
+}
+
+// applyPullSecrets add image pull secrets to pod spec, which are not declared in template
+func applyPullSecrets(spec *apiv1.PodSpec, refs []apiv1.LocalObjectReference) {
+	for _, ref := range refs {
+		if !hasPullSecret(spec.ImagePullSecrets, ref.Name) {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets, ref)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"demius.md/deployment-operator/gitclient"
+)
+
+const (
+	// SecretRefreshInterval is interval of reloading of k8s secret with token of provider
+	SecretRefreshInterval = 30 * time.Second
+	// SecretRetryInterval is interval of retry of reading of k8s secret after error
+	SecretRetryInterval = 5 * time.Second
+	// namespaceFile contains namespace of pod of operator
+	namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// SecretKeyRef is key of k8s secret, namespace of operator is used by default
+type SecretKeyRef struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+}
+
+// tokenSource create source of secret token of provider, only one of token sources may be declared
+func (c ProviderConfig) tokenSource(clientset kubernetes.Interface) (gitclient.TokenSource, error) {
+	return newTokenSource("secret-token", c.Secret, c.SecretFile, c.SecretEnv, c.SecretRef, clientset)
+}
+
+// newTokenSource create source of secret declared by value, file, environment variable or key of k8s secret.
+// Name is name of field of config with value, other fields have suffixes `-file`, `-env` and `-ref`
+func newTokenSource(name, value, file, env string, ref *SecretKeyRef, clientset kubernetes.Interface) (gitclient.TokenSource, error) {
+	var sources []gitclient.TokenSource
+	if value != "" {
+		sources = append(sources, gitclient.StaticToken(value))
+	}
+	if file != "" {
+		sources = append(sources, fileToken(file))
+	}
+	if env != "" {
+		sources = append(sources, envToken(env))
+	}
+	if ref != nil {
+		if ref.Name == "" || ref.Key == "" {
+			return nil, fmt.Errorf("name and key of `%s-ref` must be declared", name)
+		}
+		sources = append(sources, &secretToken{clientset: clientset, ref: *ref})
+	}
+
+	if len(sources) > 1 {
+		return nil, fmt.Errorf("only one of `%[1]s`, `%[1]s-file`, `%[1]s-env` and `%[1]s-ref` may be declared", name)
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	return sources[0], nil
+}
+
+// fileToken read token from file for every request, e.g. from mounted k8s secret
+type fileToken string
+
+func (f fileToken) Token() (string, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("can not read secret token file: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// envToken read token from environment variable
+type envToken string
+
+func (e envToken) Token() (string, error) {
+	token, ok := os.LookupEnv(string(e))
+	if !ok {
+		return "", fmt.Errorf("environment variable `%s` with secret token is not set", string(e))
+	}
+	return token, nil
+}
+
+// secretToken read token from k8s secret, secret is reloaded after SecretRefreshInterval.
+// Errors of reading are kept for SecretRetryInterval, so k8s api is not requested on each call
+type secretToken struct {
+	clientset kubernetes.Interface
+	ref       SecretKeyRef
+
+	lock    sync.Mutex
+	token   string
+	err     error
+	expires time.Time
+}
+
+func (s *secretToken) Token() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if time.Now().Before(s.expires) {
+		return s.token, s.err
+	}
+
+	ns := s.ref.Namespace
+	if ns == "" {
+		ns = operatorNamespace()
+	}
+	secret, err := s.clientset.CoreV1().Secrets(ns).Get(context.Background(), s.ref.Name, metav1.GetOptions{})
+	if err != nil {
+		s.expires = time.Now().Add(SecretRetryInterval)
+		if s.token != "" {
+			// previous token is used, while k8s api is not available
+			log.Printf("can not reload secret `%s/%s`, previous token is used: %v\n", ns, s.ref.Name, err)
+			return s.token, nil
+		}
+		s.err = fmt.Errorf("can not get secret `%s/%s`: %v", ns, s.ref.Name, err)
+		return "", s.err
+	}
+	value, ok := secret.Data[s.ref.Key]
+	if !ok {
+		s.token, s.expires = "", time.Now().Add(SecretRetryInterval)
+		s.err = fmt.Errorf("secret `%s/%s` does not contain key `%s`", ns, s.ref.Name, s.ref.Key)
+		return "", s.err
+	}
+
+	s.token, s.err = strings.TrimSpace(string(value)), nil
+	s.expires = time.Now().Add(SecretRefreshInterval)
+	return s.token, nil
+}
+
+// operatorNamespace return namespace of pod of operator, `default` when operator runs outside of cluster
+func operatorNamespace() string {
+	data, err := ioutil.ReadFile(namespaceFile)
+	if err != nil {
+		return metav1.NamespaceDefault
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSecretTokenBackoff(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tokens", Namespace: "ops"},
+		Data:       map[string][]byte{"gitlab": []byte("secret\n")},
+	})
+	calls, failing := 0, false
+	clientset.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if failing {
+			return true, nil, fmt.Errorf("api is not available")
+		}
+		return false, nil, nil
+	})
+
+	source := &secretToken{clientset: clientset, ref: SecretKeyRef{Namespace: "ops", Name: "tokens", Key: "gitlab"}}
+	if token, err := source.Token(); err != nil || token != "secret" {
+		t.Fatalf("Token = %s, %v", token, err)
+	}
+
+	// previous token is used, when secret can not be reloaded
+	failing, source.expires = true, time.Now()
+	if token, err := source.Token(); err != nil || token != "secret" {
+		t.Errorf("Token with failed reload = %s, %v, expected previous token", token, err)
+	}
+
+	// error is kept until retry interval
+	source.token, source.expires = "", time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := source.Token(); err == nil {
+			t.Errorf("error of secret is not returned")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("secret is requested %d times, expected 3", calls)
+	}
+}
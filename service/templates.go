@@ -20,6 +20,9 @@ const (
 	ServiceKind
 	// CronJobKind for cronjob template
 	CronJobKind
+	// ResourceKind for templates of any other k8s resources, applied via dynamic client.
+	// Templates of this kind are loaded from ResourcesDir and mapped by full template name: `<kind>-<tier>` or `<kind>`
+	ResourceKind
 )
 
 const (
@@ -29,6 +32,8 @@ const (
 	DeploymentName = "deployment"
 	// ServiceName contains k8s manifest for service resource
 	ServiceName = "service"
+	// ResourcesDir is subdirectory of templates of other k8s resources
+	ResourcesDir = "resources"
 )
 
 // TemplatesByTier map from tiers (ui, api etc) to templates
@@ -67,17 +72,18 @@ func LoadTemplates(source string) (Templates, error) {
 		}
 
 		var artifactKind ArtifactKind
-		switch artifactName {
-		case CronJobName:
+		switch {
+		case filepath.Dir(path) == filepath.Join(source, ResourcesDir):
+			artifactKind = ResourceKind
+			artifactTier = templateName
+		case artifactName == CronJobName:
 			artifactKind = CronJobKind
-		case DeploymentName:
+		case artifactName == DeploymentName:
 			artifactKind = DeploymentKind
-		case ServiceName:
+		case artifactName == ServiceName:
 			artifactKind = ServiceKind
 		default:
-			{
-				return fmt.Errorf("unknown template type: %s", artifactName)
-			}
+			return fmt.Errorf("unknown template type: %s, templates of other resources must be placed in `%s` directory", artifactName, ResourcesDir)
 		}
 
 		filedata, err := ioutil.ReadFile(path)
@@ -97,7 +103,7 @@ func LoadTemplates(source string) (Templates, error) {
 		tbt[artifactTier] = parsedTmpl
 		log.Printf("template %s loaded as %s for tier: %s\n", filename, artifactName, artifactTier)
 
-		cnt ++
+		cnt++
 
 		return nil
 	})
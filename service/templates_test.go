@@ -0,0 +1,61 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemplates(t *testing.T, files ...string) string {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("kind: {{ .Kind }}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir := writeTemplates(t, "deployment.yaml", "deployment-api.yaml", "cronjob.yml", "service.yaml",
+		"resources/ingress.yaml", "resources/configmap-api.yaml")
+	defer os.RemoveAll(dir)
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates error: %v", err)
+	}
+	expected := map[ArtifactKind][]string{
+		DeploymentKind: {"", "api"},
+		CronJobKind:    {""},
+		ServiceKind:    {""},
+		ResourceKind:   {"ingress", "configmap-api"},
+	}
+	for kind, tiers := range expected {
+		if len(templates[kind]) != len(tiers) {
+			t.Errorf("templates of kind %d: %v, expected tiers %v", kind, templates[kind], tiers)
+		}
+		for _, tier := range tiers {
+			if templates[kind][tier] == nil {
+				t.Errorf("template of kind %d for tier `%s` is not loaded", kind, tier)
+			}
+		}
+	}
+}
+
+func TestLoadTemplatesUnknownName(t *testing.T) {
+	dir := writeTemplates(t, "deployment.yaml", "deploymnet-api.yaml")
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Errorf("template with unknown name is loaded without error")
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"demius.md/deployment-operator/api"
+	"demius.md/deployment-operator/gitclient"
+)
+
+const (
+	// WebhookPath is prefix of url of webhooks, full url is `/webhooks/<provider>`
+	WebhookPath = "/webhooks/"
+	// WebhookMaxBodySize is maximum size of webhook payload
+	WebhookMaxBodySize = 1024 * 1024
+)
+
+// WebhookConf contains settings of http listener of webhooks from git providers
+type WebhookConf struct {
+	Port    int    `yaml:"port"`    // webhooks are disabled, when port is not declared
+	TLS     bool   `yaml:"tls"`     // use key/cert files from `certs`
+	Mode    string `yaml:"mode"`    // server mode of deployment: devel or prod, default devel
+	Cluster string `yaml:"cluster"` // cluster of kustomizations not bound to cluster, default-cluster by default
+}
+
+// webhookEvent is release of project reported by webhook
+type webhookEvent struct {
+	group   string
+	project string
+	tag     string
+}
+
+type githubPayload struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName string `json:"tag_name"`
+	} `json:"release"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+type gitlabPayload struct {
+	ObjectKind string `json:"object_kind"`
+	Action     string `json:"action"`
+	Tag        string `json:"tag"`
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type webhookHandler struct {
+	server  *deploymentServer
+	mode    api.ServerMode
+	cluster string
+	queue   *deployQueue
+}
+
+// deployQueue contains kustomizations reported by webhooks, kustomization is queued once until its deployment starts
+type deployQueue struct {
+	lock    sync.Mutex
+	pending []queuedDeploy
+	queued  map[string]bool
+	wakeup  chan struct{}
+}
+
+// queuedDeploy is kustomization waiting for deployment
+type queuedDeploy struct {
+	path  string
+	actor string
+}
+
+func newDeployQueue() *deployQueue {
+	return &deployQueue{queued: make(map[string]bool), wakeup: make(chan struct{}, 1)}
+}
+
+// push add kustomizations, which are not queued yet, and return number of added ones
+func (q *deployQueue) push(paths []string, actor string) int {
+	q.lock.Lock()
+	added := 0
+	for _, path := range paths {
+		if !q.queued[path] {
+			q.queued[path] = true
+			q.pending = append(q.pending, queuedDeploy{path, actor})
+			added++
+		}
+	}
+	q.lock.Unlock()
+
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+	return added
+}
+
+// pop take first queued kustomization, false is returned for empty queue
+func (q *deployQueue) pop() (queuedDeploy, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.pending) == 0 {
+		return queuedDeploy{}, false
+	}
+	item := q.pending[0]
+	q.pending = q.pending[1:]
+	delete(q.queued, item.path)
+	return item, true
+}
+
+// run deploy queued kustomizations one by one
+func (h *webhookHandler) run() {
+	for range h.queue.wakeup {
+		for {
+			item, ok := h.queue.pop()
+			if !ok {
+				break
+			}
+			h.server.deployKustomizations(withActor(context.Background(), item.actor), []string{item.path}, h.mode, h.cluster)
+		}
+	}
+}
+
+// WebhookHandler create http handler of release webhooks from GitHub, GitLab and Gitea
+func (s *deploymentServer) WebhookHandler(conf WebhookConf) http.Handler {
+	if conf.Mode == "" {
+		conf.Mode = "devel"
+	}
+	h := &webhookHandler{s, serverMode(conf.Mode), s.resolveCluster(conf.Cluster), newDeployQueue()}
+	go h.run()
+	return h
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider := strings.TrimPrefix(r.URL.Path, WebhookPath)
+	providerConf, ok := h.server.providers[provider]
+	if !ok {
+		http.Error(w, "unknown provider", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, WebhookMaxBodySize))
+	if err != nil {
+		http.Error(w, "can not read payload", http.StatusBadRequest)
+		return
+	}
+
+	event, err := parseWebhook(r.Header, body, providerConf.WebhookSecret)
+	if err != nil {
+		log.Printf("webhook of provider %s rejected: %v\n", provider, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if event == nil {
+		// ping or event not related to releases
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	paths, err := h.server.findKustomizations(provider, event.group, event.project, h.cluster)
+	if err != nil {
+		log.Printf("webhook of provider %s: %v\n", provider, err)
+		http.Error(w, "can not find kustomizations", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("webhook of provider %s: release %s of %s/%s, kustomizations: %v\n", provider, event.tag, event.group, event.project, paths)
+
+	queued := 0
+	if len(paths) > 0 {
+		if gitcli, ok := h.server.gitclients[provider].(*gitclient.CachedClient); ok {
+			gitcli.Invalidate(event.group, event.project)
+		}
+		// providers do not wait for deployment, so it is done in background
+		queued = h.queue.push(paths, "webhook/"+provider)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "%d kustomizations will be deployed, %d are queued already\n", queued, len(paths)-queued)
+}
+
+// parseWebhook verify signature or token of webhook and parse release event, nil event is returned for other events
+func parseWebhook(header http.Header, body []byte, secret string) (*webhookEvent, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("webhook secret of provider is not configured")
+	}
+
+	switch {
+	case header.Get("X-Gitea-Event") != "" || header.Get("X-Forgejo-Event") != "":
+		if !validSignature(header.Get("X-Gitea-Signature"), body, secret) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		if header.Get("X-Gitea-Event") != "release" && header.Get("X-Forgejo-Event") != "release" {
+			return nil, nil
+		}
+		return parseGithubRelease(body)
+
+	case header.Get("X-GitHub-Event") != "":
+		signature := header.Get("X-Hub-Signature-256")
+		if !strings.HasPrefix(signature, "sha256=") || !validSignature(signature[len("sha256="):], body, secret) {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		if header.Get("X-GitHub-Event") != "release" {
+			return nil, nil
+		}
+		return parseGithubRelease(body)
+
+	case header.Get("X-Gitlab-Event") != "":
+		if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+			return nil, fmt.Errorf("invalid token")
+		}
+		return parseGitlabRelease(body)
+	}
+	return nil, fmt.Errorf("unknown webhook")
+}
+
+func validSignature(signature string, body []byte, secret string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parseGithubRelease parse release event of GitHub or Gitea
+func parseGithubRelease(body []byte) (*webhookEvent, error) {
+	var payload githubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("can not parse payload: %v", err)
+	}
+	if payload.Action != "published" && payload.Action != "released" {
+		return nil, nil
+	}
+	return &webhookEvent{payload.Repository.Owner.Login, payload.Repository.Name, payload.Release.TagName}, nil
+}
+
+// parseGitlabRelease parse release or tag push event of GitLab
+func parseGitlabRelease(body []byte) (*webhookEvent, error) {
+	var payload gitlabPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("can not parse payload: %v", err)
+	}
+
+	var tag string
+	switch payload.ObjectKind {
+	case "release":
+		if payload.Action != "create" {
+			return nil, nil
+		}
+		tag = payload.Tag
+	case "tag_push":
+		// removed tag has empty `after` commit
+		if strings.Trim(payload.After, "0") == "" {
+			return nil, nil
+		}
+		tag = strings.TrimPrefix(payload.Ref, "refs/tags/")
+	default:
+		return nil, nil
+	}
+
+	path := payload.Project.PathWithNamespace
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid path of project `%s`", path)
+	}
+	return &webhookEvent{path[:idx], path[idx+1:], tag}, nil
+}
+
+// findKustomizations find kustomizations with repository of project, kustomizations with `auto-deploy: false`
+// and kustomizations bound to other cluster are skipped
+func (s *deploymentServer) findKustomizations(provider, group, project, clusterName string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(s.kustomizations, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walk dir%s: %v", path, err)
+		}
+		if f.IsDir() || filepath.Base(path) != "kustomization.yaml" {
+			return nil
+		}
+
+		filedata, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		kustomization, err := ParseKustomization(filedata)
+		if err != nil {
+			log.Printf("skip kustomization %s: %v\n", path, err)
+			return nil
+		}
+
+		repo := &kustomization.Repository
+		if kustomization.AutoDeploy != nil && !*kustomization.AutoDeploy {
+			return nil
+		}
+		if kustomization.Cluster != "" && kustomization.Cluster != clusterName {
+			return nil
+		}
+		if repo.Provider == provider && strings.EqualFold(repo.Group, group) && strings.EqualFold(repo.Project, project) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// deployKustomizations run deployment of kustomizations, the same as Deploy does
+func (s *deploymentServer) deployKustomizations(ctx context.Context, paths []string, mode api.ServerMode, clusterName string) []*api.ServiceInfo {
+	prefixLen := len(s.kustomizations) + 1
+	services := make([]*api.ServiceInfo, 0, len(paths))
+	for _, path := range paths {
+		serviceInfo := s.handleKustomization(ctx, prefixLen, path, false, mode, clusterName)
+		log.Printf("deploy %s: %v\n", serviceInfo.Path, serviceInfo)
+		services = append(services, serviceInfo)
+	}
+	return services
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	body := []byte(`{"action":"published"}`)
+	tests := []struct {
+		name      string
+		signature string
+		valid     bool
+	}{
+		{"valid signature", sign(string(body), "secret"), true},
+		{"signature with other secret", sign(string(body), "other"), false},
+		{"signature of other body", sign(`{}`, "secret"), false},
+		{"signature is not hex", "not-hex", false},
+		{"empty signature", "", false},
+	}
+	for _, test := range tests {
+		if valid := validSignature(test.signature, body, "secret"); valid != test.valid {
+			t.Errorf("%s: validSignature = %v, expected %v", test.name, valid, test.valid)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	githubRelease := `{"action":"published","release":{"tag_name":"v1.2.0"},"repository":{"name":"app","owner":{"login":"group"}}}`
+	githubDraft := `{"action":"created","release":{"tag_name":"v1.2.0"},"repository":{"name":"app","owner":{"login":"group"}}}`
+	gitlabRelease := `{"object_kind":"release","action":"create","tag":"v1.2.0","project":{"path_with_namespace":"group/sub/app"}}`
+	gitlabTag := `{"object_kind":"tag_push","ref":"refs/tags/v1.3.0","after":"abc123","project":{"path_with_namespace":"group/app"}}`
+	gitlabRemovedTag := `{"object_kind":"tag_push","ref":"refs/tags/v1.3.0","after":"0000000000","project":{"path_with_namespace":"group/app"}}`
+	gitlabPush := `{"object_kind":"push","project":{"path_with_namespace":"group/app"}}`
+
+	tests := []struct {
+		name    string
+		header  map[string]string
+		body    string
+		secret  string
+		event   *webhookEvent
+		invalid bool
+	}{
+		{
+			name:   "github release",
+			header: map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": "sha256=" + sign(githubRelease, "secret")},
+			body:   githubRelease,
+			event:  &webhookEvent{"group", "app", "v1.2.0"},
+		},
+		{
+			name:   "github release is not published",
+			header: map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": "sha256=" + sign(githubDraft, "secret")},
+			body:   githubDraft,
+		},
+		{
+			name:   "github other event",
+			header: map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(`{}`, "secret")},
+			body:   `{}`,
+		},
+		{
+			name:    "github signature without prefix",
+			header:  map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": sign(githubRelease, "secret")},
+			body:    githubRelease,
+			invalid: true,
+		},
+		{
+			name:    "github invalid signature",
+			header:  map[string]string{"X-GitHub-Event": "release", "X-Hub-Signature-256": "sha256=" + sign(githubRelease, "other")},
+			body:    githubRelease,
+			invalid: true,
+		},
+		{
+			name:   "gitea release",
+			header: map[string]string{"X-Gitea-Event": "release", "X-Gitea-Signature": sign(githubRelease, "secret")},
+			body:   githubRelease,
+			event:  &webhookEvent{"group", "app", "v1.2.0"},
+		},
+		{
+			name:   "forgejo release",
+			header: map[string]string{"X-Forgejo-Event": "release", "X-Gitea-Signature": sign(githubRelease, "secret")},
+			body:   githubRelease,
+			event:  &webhookEvent{"group", "app", "v1.2.0"},
+		},
+		{
+			name:    "gitea invalid signature",
+			header:  map[string]string{"X-Gitea-Event": "release", "X-Gitea-Signature": "00"},
+			body:    githubRelease,
+			invalid: true,
+		},
+		{
+			name:   "gitlab release in subgroup",
+			header: map[string]string{"X-Gitlab-Event": "Release Hook", "X-Gitlab-Token": "secret"},
+			body:   gitlabRelease,
+			event:  &webhookEvent{"group/sub", "app", "v1.2.0"},
+		},
+		{
+			name:   "gitlab tag push",
+			header: map[string]string{"X-Gitlab-Event": "Tag Push Hook", "X-Gitlab-Token": "secret"},
+			body:   gitlabTag,
+			event:  &webhookEvent{"group", "app", "v1.3.0"},
+		},
+		{
+			name:   "gitlab removed tag",
+			header: map[string]string{"X-Gitlab-Event": "Tag Push Hook", "X-Gitlab-Token": "secret"},
+			body:   gitlabRemovedTag,
+		},
+		{
+			name:   "gitlab other event",
+			header: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "secret"},
+			body:   gitlabPush,
+		},
+		{
+			name:    "gitlab invalid token",
+			header:  map[string]string{"X-Gitlab-Event": "Release Hook", "X-Gitlab-Token": "other"},
+			body:    gitlabRelease,
+			invalid: true,
+		},
+		{
+			name:    "gitlab invalid payload",
+			header:  map[string]string{"X-Gitlab-Event": "Release Hook", "X-Gitlab-Token": "secret"},
+			body:    `{`,
+			invalid: true,
+		},
+		{
+			name:    "unknown webhook",
+			header:  map[string]string{"X-Other-Event": "release"},
+			body:    githubRelease,
+			invalid: true,
+		},
+		{
+			name:    "secret is not configured",
+			header:  map[string]string{"X-Gitlab-Event": "Release Hook", "X-Gitlab-Token": ""},
+			body:    gitlabRelease,
+			secret:  "-",
+			invalid: true,
+		},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		for key, value := range test.header {
+			header.Set(key, value)
+		}
+		secret := "secret"
+		if test.secret == "-" {
+			secret = ""
+		}
+
+		event, err := parseWebhook(header, []byte(test.body), secret)
+		if (err != nil) != test.invalid {
+			t.Errorf("%s: parseWebhook error %v, expected invalid %v", test.name, err, test.invalid)
+			continue
+		}
+		if !reflect.DeepEqual(event, test.event) {
+			t.Errorf("%s: parseWebhook = %+v, expected %+v", test.name, event, test.event)
+		}
+	}
+}
+
+func TestDeployQueue(t *testing.T) {
+	queue := newDeployQueue()
+	if added := queue.push([]string{"a", "b"}, "webhook/gitlab"); added != 2 {
+		t.Errorf("push added %d kustomizations, expected 2", added)
+	}
+	if added := queue.push([]string{"b", "c"}, "webhook/github"); added != 1 {
+		t.Errorf("push added %d kustomizations, expected only not queued one", added)
+	}
+
+	var paths []string
+	for {
+		item, ok := queue.pop()
+		if !ok {
+			break
+		}
+		paths = append(paths, item.path)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("queued kustomizations %v, expected %v", paths, expected)
+	}
+	if added := queue.push([]string{"a"}, "webhook/gitlab"); added != 1 {
+		t.Errorf("deployed kustomization is not queued again")
+	}
+}
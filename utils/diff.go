@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffLine struct {
+	op   byte
+	text string
+	a, b int // line numbers in texts before this line
+}
+
+// UnifiedDiff compare lines of two texts and return differences in unified format,
+// empty string is returned when texts are equal
+func UnifiedDiff(fromName, toName string, from, to []string, context int) string {
+	lines := diffLines(from, to)
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		// find first changed line of next hunk
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// extend hunk while number of equal lines between changes does not exceed two contexts
+		last := first
+		for i := first; i < len(lines) && i-last-1 <= 2*context; i++ {
+			if lines[i].op != ' ' {
+				last = i
+			}
+		}
+
+		begin := first - context
+		if begin < start {
+			begin = start
+		}
+		if begin < 0 {
+			begin = 0
+		}
+		end := last + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		writeHunk(&out, lines[begin:end])
+		start = end
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, hunk []diffLine) {
+	fromLen, toLen := 0, 0
+	for _, l := range hunk {
+		if l.op != '+' {
+			fromLen++
+		}
+		if l.op != '-' {
+			toLen++
+		}
+	}
+	fromStart, toStart := hunk[0].a, hunk[0].b
+	if fromLen > 0 {
+		fromStart++
+	}
+	if toLen > 0 {
+		toStart++
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", fromStart, fromLen, toStart, toLen)
+	for _, l := range hunk {
+		out.WriteByte(l.op)
+		out.WriteString(l.text)
+		out.WriteByte('\n')
+	}
+}
+
+// diffLines build edit script based on longest common subsequence of lines
+func diffLines(from, to []string) []diffLine {
+	n, m := len(from), len(to)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && from[i] == to[j]:
+			lines = append(lines, diffLine{' ', from[i], i, j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', from[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', to[j], i, j})
+			j++
+		}
+	}
+	return lines
+}
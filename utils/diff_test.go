@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		context  int
+		diff     string
+	}{
+		{
+			name: "equal texts",
+			from: "a\nb",
+			to:   "a\nb",
+		},
+		{
+			name:    "changed line with context",
+			from:    "a\nb\nc\nd\ne",
+			to:      "a\nb\nC\nd\ne",
+			context: 1,
+			diff:    "--- live\n+++ rendered\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+		},
+		{
+			name:    "lines added to empty text",
+			from:    "",
+			to:      "a\nb",
+			context: 3,
+			diff:    "--- live\n+++ rendered\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "all lines removed",
+			from:    "a\nb",
+			to:      "",
+			context: 3,
+			diff:    "--- live\n+++ rendered\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "distant changes in separate hunks",
+			from:    "a\nb\nc\nd\ne\nf\ng",
+			to:      "A\nb\nc\nd\ne\nf\nG",
+			context: 1,
+			diff:    "--- live\n+++ rendered\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -6,2 +6,2 @@\n f\n-g\n+G\n",
+		},
+		{
+			name:    "close changes in one hunk",
+			from:    "a\nb\nc\nd",
+			to:      "A\nb\nc\nD",
+			context: 1,
+			diff:    "--- live\n+++ rendered\n@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n-d\n+D\n",
+		},
+	}
+	for _, test := range tests {
+		diff := UnifiedDiff("live", "rendered", lines(test.from), lines(test.to), test.context)
+		if diff != test.diff {
+			t.Errorf("%s: UnifiedDiff =\n%s\nexpected\n%s", test.name, diff, test.diff)
+		}
+	}
+}
+
+func lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}